@@ -5,9 +5,11 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"pay_flow_go/internal/config"
 	"pay_flow_go/internal/logger"
+	"pay_flow_go/internal/pii"
 	"pay_flow_go/internal/server"
 
 	"github.com/rs/zerolog"
@@ -27,7 +29,18 @@ func main() {
 
 	lvl := zerolog.Level(cfg.LogLevel)
 	logger.Init(lvl, cfg)
-	log.Info().Msgf("config loaded: %+v", cfg)
+	pii.SetMode(pii.ParseMode(cfg.PIIMasking))
+	log.Info().Object("config", cfg).Msg("config loaded")
+
+	// pay_flow_go topics — только создать/проверить топики и выйти
+	if len(os.Args) > 1 && os.Args[1] == "topics" {
+		if err := server.EnsureTopics(context.Background(), cfg); err != nil {
+			log.Fatal().Err(err).Msg("topics ensure failed")
+		}
+		log.Info().Msg("topics ensured")
+		return
+	}
+
 	srv, err := server.New(cfg)
 
 	if err != nil {
@@ -37,6 +50,13 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// SIGHUP или изменённый .env — перечитать runtime-часть конфига.
+	// Консьюмера и проверки редиректа здесь нет, поэтому подписан только
+	// уровень логов; Consumer.Tune и RedirectAllowed — для сервисов, которые их строят.
+	rl := config.NewReloader(cfg, ".env")
+	rl.Subscribe(func(rt config.Runtime) { zerolog.SetGlobalLevel(zerolog.Level(rt.LogLevel)) })
+	go rl.Watch(ctx, 5*time.Second)
+
 	go func() {
 		if err := srv.Run(ctx); err != nil {
 			log.Fatal().Err(err).Msg("server error")
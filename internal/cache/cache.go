@@ -1,8 +1,28 @@
 package cache
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound — ключа нет (или он истёк). Пустое значение — не промах.
+var ErrNotFound = errors.New("cache: key not found")
+
+// Store — кэш "ключ → строка". Все операции принимают ctx: его дедлайн
+// ограничивает запрос; без дедлайна реализация ставит свой таймаут.
+// ttl <= 0 — без истечения.
 type Store interface {
-	Get(key string) (string, error)
-	Set(key, value string) error
-	Delete(key string) error
+	// Get возвращает ErrNotFound, если ключа нет.
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error
+	// MGet — найденные ключи; отсутствующих в результате нет.
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
+	MSet(ctx context.Context, values map[string]string, ttl time.Duration) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// Incr увеличивает счётчик на 1; ttl ставится, когда ключ создаётся.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Delete(ctx context.Context, key string) error
 	Close() error
 }
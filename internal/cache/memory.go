@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrNotInteger = errors.New("cache: value is not an integer")
+
+// MemoryCache — Store в памяти процесса: не больше maxEntries ключей
+// (вытесняется давно не использованный), TTL на ключ и фоновая
+// чистка истёкших. Безопасен для конкурентного использования.
+type MemoryCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List // front — самый свежий
+	items map[string]*list.Element
+
+	now      func() time.Time
+	interval time.Duration // период janitor'а; 0 — без него
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+type memEntry struct {
+	key     string
+	value   string
+	expires time.Time // zero — без истечения
+}
+
+var _ Store = (*MemoryCache)(nil)
+
+// MemoryOption — опциональная настройка MemoryCache.
+type MemoryOption func(*MemoryCache)
+
+// WithClock подменяет часы (для тестов).
+func WithClock(now func() time.Time) MemoryOption {
+	return func(m *MemoryCache) { m.now = now }
+}
+
+// WithJanitorInterval — период чистки истёкших ключей; 0 — выключить
+// (истёкшие всё равно не отдаются и удаляются при обращении).
+func WithJanitorInterval(d time.Duration) MemoryOption {
+	return func(m *MemoryCache) { m.interval = d }
+}
+
+// NewMemory — maxEntries <= 0 — без ограничения размера.
+func NewMemory(maxEntries int, opts ...MemoryOption) *MemoryCache {
+	m := &MemoryCache{
+		max:      maxEntries,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+		now:      time.Now,
+		interval: time.Minute,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.interval > 0 {
+		go m.janitor()
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key, value string) error {
+	return m.SetWithTTL(ctx, key, value, 0)
+}
+
+func (m *MemoryCache) SetWithTTL(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryCache) MGet(_ context.Context, keys ...string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if e := m.lookup(k); e != nil {
+			out[k] = e.value
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryCache) MSet(_ context.Context, values map[string]string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range values {
+		m.put(k, v, ttl)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Exists(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookup(key) != nil, nil
+}
+
+func (m *MemoryCache) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.lookup(key)
+	if e == nil {
+		m.put(key, "1", ttl)
+		return 1, nil
+	}
+	n, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	n++
+	e.value = strconv.FormatInt(n, 10) // срок жизни не продлевается, как в Redis
+	return n, nil
+}
+
+func (m *MemoryCache) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+// Len — число ключей, включая истёкшие, но ещё не вычищенные.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+// Close останавливает janitor. Повторный вызов безопасен.
+func (m *MemoryCache) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	<-m.done
+	return nil
+}
+
+// lookup — живая запись с продвижением в LRU; истёкшая удаляется. Под mu.
+func (m *MemoryCache) lookup(key string) *memEntry {
+	el, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*memEntry)
+	if m.expired(e, m.now()) {
+		m.remove(el)
+		return nil
+	}
+	m.ll.MoveToFront(el)
+	return e
+}
+
+// put вставляет или заменяет ключ и вытесняет лишнее с хвоста. Под mu.
+func (m *MemoryCache) put(key, value string, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = m.now().Add(ttl)
+	}
+	if el, ok := m.items[key]; ok {
+		e := el.Value.(*memEntry)
+		e.value, e.expires = value, expires
+		m.ll.MoveToFront(el)
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memEntry{key: key, value: value, expires: expires})
+	for m.max > 0 && m.ll.Len() > m.max {
+		m.remove(m.ll.Back())
+	}
+}
+
+func (m *MemoryCache) remove(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*memEntry).key)
+}
+
+func (m *MemoryCache) expired(e *memEntry, now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (m *MemoryCache) janitor() {
+	defer close(m.done)
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+			m.sweep()
+		}
+	}
+}
+
+// sweep удаляет все истёкшие записи.
+func (m *MemoryCache) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for el := m.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if m.expired(el.Value.(*memEntry), now) {
+			m.remove(el)
+		}
+		el = prev
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2, WithJanitorInterval(0))
+	defer m.Close()
+
+	_ = m.Set(ctx, "a", "1")
+	_ = m.Set(ctx, "b", "2")
+	_, _ = m.Get(ctx, "a") // a свежее b
+	_ = m.Set(ctx, "c", "3")
+
+	if _, err := m.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("b must be evicted, got %v", err)
+	}
+	for _, k := range []string{"a", "c"} {
+		if ok, _ := m.Exists(ctx, k); !ok {
+			t.Fatalf("%s evicted", k)
+		}
+	}
+}
+
+func TestMemoryCache_SweepRemovesExpired(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock()
+	m := NewMemory(0, WithClock(clk.Now), WithJanitorInterval(0))
+	defer m.Close()
+
+	_ = m.SetWithTTL(ctx, "old", "v", time.Second)
+	_ = m.Set(ctx, "keep", "v")
+	clk.Advance(time.Minute)
+
+	m.sweep()
+	if n := m.Len(); n != 1 {
+		t.Fatalf("Len after sweep: %d, want 1", n)
+	}
+}
+
+func TestMemoryCache_CloseIdempotent(t *testing.T) {
+	m := NewMemory(10, WithJanitorInterval(time.Millisecond))
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = m.Close()
+}
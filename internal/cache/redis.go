@@ -12,9 +12,13 @@ import (
 var ErrNotImplemented = errors.New("not implemented")
 
 type RedisCache struct {
-	clt *redis.Client
+	clt       *redis.Client
+	opTimeout time.Duration // для ctx без дедлайна
 }
 
+// defaultOpTimeout — таймаут операции, если у ctx нет дедлайна.
+const defaultOpTimeout = 250 * time.Millisecond
+
 var _ Store = (*RedisCache)(nil)
 
 func New(dsn string) (*RedisCache, error) {
@@ -60,27 +64,134 @@ func New(dsn string) (*RedisCache, error) {
 		_ = clt.Close()
 		return nil, err
 	}
-	return &RedisCache{clt: clt}, nil
+	return &RedisCache{clt: clt, opTimeout: defaultOpTimeout}, nil
 }
 
-func (r *RedisCache) Get(key string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+// withTimeout ограничивает запрос opTimeout, если у ctx нет своего дедлайна.
+func (r *RedisCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.opTimeout)
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 	res, err := r.clt.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return "", nil
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
 	}
 	return res, err
 }
 
-func (r *RedisCache) Set(key, value string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+func (r *RedisCache) Set(ctx context.Context, key, value string) error {
+	return r.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL — Set с временем жизни ключа; ttl <= 0 — без истечения.
+func (r *RedisCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.clt.Set(ctx, key, value, max(ttl, 0)).Err()
+}
+
+func (r *RedisCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	out := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
-	return r.clt.Set(ctx, key, value, 0).Err()
+	vals, err := r.clt.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[keys[i]] = s
+		}
+	}
+	return out, nil
+}
+
+// getWithTTL — значения и оставшийся срок жизни (PTTL) ключей одной
+// транзакцией. Отсутствующих ключей в результате нет; срок -1 — без TTL.
+func (r *RedisCache) getWithTTL(ctx context.Context, keys ...string) (map[string]string, map[string]time.Duration, error) {
+	vals := make(map[string]string, len(keys))
+	ttls := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return vals, ttls, nil
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	gets := make([]*redis.StringCmd, len(keys))
+	pttls := make([]*redis.DurationCmd, len(keys))
+	_, err := r.clt.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for i, k := range keys {
+			gets[i] = p.Get(ctx, k)
+			pttls[i] = p.PTTL(ctx, k)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+	for i, k := range keys {
+		v, err := gets[i].Result()
+		if err != nil {
+			continue
+		}
+		vals[k], ttls[k] = v, pttls[i].Val()
+	}
+	return vals, ttls, nil
+}
+
+// MSet пишет все значения одним pipeline (MSET не умеет TTL).
+func (r *RedisCache) MSet(ctx context.Context, values map[string]string, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.clt.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for k, v := range values {
+			p.Set(ctx, k, v, max(ttl, 0))
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	n, err := r.clt.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+// incrScript — INCR и PEXPIRE для нового ключа атомарно.
+var incrScript = redis.NewScript(`
+local v = redis.call('INCR', KEYS[1])
+if v == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return v
+`)
+
+func (r *RedisCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	n, err := incrScript.Run(ctx, r.clt, []string{key}, max(ttl, 0).Milliseconds()).Int64()
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, ErrNotInteger
+	}
+	return n, err
 }
 
-func (r *RedisCache) Delete(key string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 	return r.clt.Del(ctx, key).Err()
 }
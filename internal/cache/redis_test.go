@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	miniredis "github.com/alicebob/miniredis/v2"
 )
@@ -57,3 +60,29 @@ func TestClose_Idempotent(t *testing.T) {
 
 	_ = rc.Close()
 }
+
+func TestSetWithTTL_Expires(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	rc, err := New(mr.Addr())
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	defer rc.Close()
+
+	ctx := context.Background()
+	if err := rc.SetWithTTL(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL error: %v", err)
+	}
+	if v, _ := rc.Get(ctx, "k"); v != "v" {
+		t.Fatalf("Get: want v, got %q", v)
+	}
+	mr.FastForward(2 * time.Minute)
+	if _, err := rc.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after ttl: want ErrNotFound, got %v", err)
+	}
+}
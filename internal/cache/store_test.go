@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+)
+
+// storeFactory — новый пустой Store и способ "прокрутить" его время.
+type storeFactory func(t *testing.T) (Store, func(time.Duration))
+
+// testStore — общий набор проверок, который обязана проходить любая реализация Store.
+func testStore(t *testing.T, newStore storeFactory) {
+	ctx := context.Background()
+
+	t.Run("MissVsEmpty", func(t *testing.T) {
+		s, _ := newStore(t)
+		if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("miss: want ErrNotFound, got %v", err)
+		}
+		if err := s.Set(ctx, "empty", ""); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := s.Get(ctx, "empty"); err != nil || v != "" {
+			t.Fatalf("empty value: %q, %v", v, err)
+		}
+	})
+
+	t.Run("SetGetDelete", func(t *testing.T) {
+		s, _ := newStore(t)
+		if err := s.Set(ctx, "k", "v"); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := s.Get(ctx, "k"); err != nil || v != "v" {
+			t.Fatalf("Get: %q, %v", v, err)
+		}
+		if ok, err := s.Exists(ctx, "k"); err != nil || !ok {
+			t.Fatalf("Exists: %v, %v", ok, err)
+		}
+		if err := s.Delete(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := s.Exists(ctx, "k"); ok {
+			t.Fatal("key survived Delete")
+		}
+		if err := s.Delete(ctx, "k"); err != nil {
+			t.Fatalf("Delete of a missing key: %v", err)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		s, advance := newStore(t)
+		if err := s.SetWithTTL(ctx, "short", "v", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetWithTTL(ctx, "forever", "v", 0); err != nil {
+			t.Fatal(err)
+		}
+		advance(30 * time.Second)
+		if ok, _ := s.Exists(ctx, "short"); !ok {
+			t.Fatal("expired too early")
+		}
+		advance(time.Minute)
+		if _, err := s.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("after ttl: want ErrNotFound, got %v", err)
+		}
+		if ok, _ := s.Exists(ctx, "forever"); !ok {
+			t.Fatal("ttl 0 must not expire")
+		}
+	})
+
+	t.Run("MSetMGet", func(t *testing.T) {
+		s, advance := newStore(t)
+		if err := s.MSet(ctx, map[string]string{"a": "1", "b": "2"}, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.MGet(ctx, "a", "b", "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+			t.Fatalf("MGet: %v", got)
+		}
+		advance(2 * time.Minute)
+		if got, _ := s.MGet(ctx, "a", "b"); len(got) != 0 {
+			t.Fatalf("MGet after ttl: %v", got)
+		}
+		if got, err := s.MGet(ctx); err != nil || len(got) != 0 {
+			t.Fatalf("MGet(): %v, %v", got, err)
+		}
+	})
+
+	t.Run("Incr", func(t *testing.T) {
+		s, advance := newStore(t)
+		for want := int64(1); want <= 3; want++ {
+			n, err := s.Incr(ctx, "hits", time.Minute)
+			if err != nil || n != want {
+				t.Fatalf("Incr: %d, %v (want %d)", n, err, want)
+			}
+		}
+		// TTL ставится при создании и не продлевается инкрементами
+		advance(2 * time.Minute)
+		if n, err := s.Incr(ctx, "hits", time.Minute); err != nil || n != 1 {
+			t.Fatalf("Incr after ttl: %d, %v (want 1)", n, err)
+		}
+
+		if err := s.Set(ctx, "word", "abc"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Incr(ctx, "word", 0); !errors.Is(err, ErrNotInteger) {
+			t.Fatalf("Incr non-integer: want ErrNotInteger, got %v", err)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		s, _ := newStore(t)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					if _, err := s.Incr(ctx, "n", 0); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if v, _ := s.Get(ctx, "n"); v != "400" {
+			t.Fatalf("concurrent Incr: %s", v)
+		}
+	})
+}
+
+func TestRedisCache_Store(t *testing.T) {
+	testStore(t, func(t *testing.T) (Store, func(time.Duration)) {
+		mr := miniredis.RunT(t)
+		rc, err := New(mr.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = rc.Close() })
+		return rc, mr.FastForward
+	})
+}
+
+func TestMemoryCache_Store(t *testing.T) {
+	testStore(t, func(t *testing.T) (Store, func(time.Duration)) {
+		clk := newFakeClock()
+		m := NewMemory(0, WithClock(clk.Now))
+		t.Cleanup(func() { _ = m.Close() })
+		return m, clk.Advance
+	})
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock { return &fakeClock{now: time.Unix(1_700_000_000, 0)} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
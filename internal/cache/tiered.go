@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultL1Size       = 10_000
+	defaultL1TTL        = 30 * time.Second
+	defaultInvalidateCh = "cache:invalidate"
+)
+
+// TieredCache — локальный LRU (L1) перед Redis (L2). Чтения идут в L1,
+// промахи схлопываются singleflight'ом в один запрос к Redis. Любая
+// запись идёт в L2, удаляет ключ из L1 и рассылает инвалидацию через
+// Redis pub/sub, чтобы остальные реплики тоже сбросили L1.
+//
+// L1 может отдавать значение до L1TTL после его изменения, если
+// инвалидация потерялась (обрыв соединения pub/sub), поэтому L1TTL
+// держим коротким. Истечение ключа в Redis инвалидацию не рассылает,
+// поэтому в L1 ключ живёт не дольше, чем ему осталось в Redis.
+type TieredCache struct {
+	l1      *MemoryCache
+	l2      *RedisCache
+	l1TTL   time.Duration
+	channel string
+	id      string // источник инвалидаций этой реплики; своё эхо listen пропускает
+
+	sf  singleflight.Group
+	gen atomic.Uint64 // растёт на каждую инвалидацию; см. fill
+
+	sub  *redis.PubSub
+	done chan struct{}
+}
+
+var _ Store = (*TieredCache)(nil)
+
+// TieredOption — опциональная настройка TieredCache.
+type TieredOption func(*TieredCache)
+
+// WithL1 — свой L1 (размер, часы); по умолчанию NewMemory(10000).
+func WithL1(m *MemoryCache) TieredOption {
+	return func(t *TieredCache) { t.l1 = m }
+}
+
+// WithL1TTL — сколько значение живёт в L1 (по умолчанию 30s).
+func WithL1TTL(d time.Duration) TieredOption {
+	return func(t *TieredCache) { t.l1TTL = d }
+}
+
+// WithInvalidationChannel — канал pub/sub; у всех реплик должен совпадать.
+func WithInvalidationChannel(name string) TieredOption {
+	return func(t *TieredCache) { t.channel = name }
+}
+
+// NewTiered подписывается на канал инвалидации и возвращает кэш.
+// TieredCache владеет l2: Close закрывает и его.
+func NewTiered(l2 *RedisCache, opts ...TieredOption) (*TieredCache, error) {
+	t := &TieredCache{
+		l2: l2, l1TTL: defaultL1TTL, channel: defaultInvalidateCh,
+		id: uuid.NewString(), done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.l1 == nil {
+		t.l1 = NewMemory(defaultL1Size)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	t.sub = l2.clt.Subscribe(ctx, t.channel)
+	// дожидаемся подтверждения подписки, иначе первые инвалидации потеряются
+	if _, err := t.sub.Receive(ctx); err != nil {
+		_ = t.sub.Close()
+		_ = t.l1.Close()
+		return nil, err
+	}
+	go t.listen()
+	return t, nil
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if v, err := t.l1.Get(ctx, key); err == nil {
+		return v, nil
+	}
+	v, err, _ := t.sf.Do(key, func() (any, error) {
+		// результат делят все ждущие — отмена ctx первого не должна их ронять
+		ctx := context.WithoutCancel(ctx)
+		gen := t.gen.Load()
+		vals, ttls, err := t.l2.getWithTTL(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		v, ok := vals[key]
+		if !ok {
+			return "", ErrNotFound
+		}
+		t.fill(ctx, gen, vals, ttls)
+		return v, nil
+	})
+	return v.(string), err
+}
+
+func (t *TieredCache) Set(ctx context.Context, key, value string) error {
+	return t.SetWithTTL(ctx, key, value, 0)
+}
+
+func (t *TieredCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := t.l2.SetWithTTL(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, key)
+}
+
+func (t *TieredCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	out, _ := t.l1.MGet(ctx, keys...)
+	var missing []string
+	for _, k := range keys {
+		if _, ok := out[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	gen := t.gen.Load()
+	found, ttls, err := t.l2.getWithTTL(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	t.fill(ctx, gen, found, ttls)
+	for k, v := range found {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (t *TieredCache) MSet(ctx context.Context, values map[string]string, ttl time.Duration) error {
+	if err := t.l2.MSet(ctx, values, ttl); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	return t.invalidate(ctx, keys...)
+}
+
+func (t *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, _ := t.l1.Exists(ctx, key); ok {
+		return true, nil
+	}
+	return t.l2.Exists(ctx, key)
+}
+
+// Incr — счётчики не кэшируются в L1: значение меняется на каждом вызове.
+func (t *TieredCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	n, err := t.l2.Incr(ctx, key, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return n, t.invalidate(ctx, key)
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	if err := t.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, key)
+}
+
+func (t *TieredCache) Close() error {
+	err := t.sub.Close()
+	<-t.done
+	return errors.Join(err, t.l1.Close(), t.l2.Close())
+}
+
+// fill кладёт прочитанное из L2 в L1, если с начала чтения не было
+// инвалидаций — иначе можно вернуть в L1 уже устаревшее значение.
+// ttls — оставшийся срок ключей в Redis (PTTL).
+func (t *TieredCache) fill(ctx context.Context, gen uint64, values map[string]string, ttls map[string]time.Duration) {
+	if len(values) == 0 || t.gen.Load() != gen {
+		return
+	}
+	for k, v := range values {
+		if ttl, ok := t.l1TTLFor(ttls[k]); ok {
+			_ = t.l1.SetWithTTL(ctx, k, v, ttl)
+		}
+	}
+}
+
+// l1TTLFor — срок в L1: L1TTL, но не дольше remaining (PTTL ключа в Redis).
+// false — ключ в Redis уже истёк, в L1 его не кладём.
+func (t *TieredCache) l1TTLFor(remaining time.Duration) (time.Duration, bool) {
+	switch {
+	case remaining == -1: // без TTL
+		return t.l1TTL, true
+	case remaining <= 0:
+		return 0, false
+	case t.l1TTL <= 0 || remaining < t.l1TTL:
+		return remaining, true
+	}
+	return t.l1TTL, true
+}
+
+// invalidation — сообщение в канале инвалидации.
+type invalidation struct {
+	Src  string   `json:"src"`
+	Keys []string `json:"keys"`
+}
+
+// invalidate сбрасывает ключи в своём L1 и рассылает их остальным репликам.
+func (t *TieredCache) invalidate(ctx context.Context, keys ...string) error {
+	t.drop(keys)
+	msg, err := json.Marshal(invalidation{Src: t.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := t.l2.withTimeout(ctx)
+	defer cancel()
+	return t.l2.clt.Publish(ctx, t.channel, msg).Err()
+}
+
+func (t *TieredCache) drop(keys []string) {
+	t.gen.Add(1)
+	for _, k := range keys {
+		_ = t.l1.Delete(context.Background(), k)
+	}
+}
+
+// listen применяет инвалидации от других реплик. Своё эхо пропускаем:
+// ключи уже сброшены в invalidate, а повторный drop выкинул бы из L1
+// значения, прочитанные уже после записи.
+func (t *TieredCache) listen() {
+	defer close(t.done)
+	for m := range t.sub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+			log.Warn().Err(err).Str("channel", m.Channel).Msg("bad cache invalidation message")
+			continue
+		}
+		if inv.Src == t.id {
+			continue
+		}
+		t.drop(inv.Keys)
+	}
+}
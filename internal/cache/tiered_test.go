@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+)
+
+func newTieredT(t *testing.T, mr *miniredis.Miniredis, opts ...TieredOption) *TieredCache {
+	t.Helper()
+	rc, err := New(mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := NewTiered(rc, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tc.Close() })
+	return tc
+}
+
+func TestTieredCache_Store(t *testing.T) {
+	testStore(t, func(t *testing.T) (Store, func(time.Duration)) {
+		mr := miniredis.RunT(t)
+		clk := newFakeClock()
+		tc := newTieredT(t, mr, WithL1(NewMemory(100, WithClock(clk.Now), WithJanitorInterval(0))))
+		return tc, func(d time.Duration) {
+			mr.FastForward(d)
+			clk.Advance(d)
+		}
+	})
+}
+
+func TestTieredCache_InvalidatesOtherReplicas(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a := newTieredT(t, mr, WithL1TTL(time.Hour))
+	b := newTieredT(t, mr, WithL1TTL(time.Hour))
+
+	if err := a.Set(ctx, "consent:ver", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := a.Get(ctx, "consent:ver"); v != "1" { // теперь в L1 реплики a
+		t.Fatalf("Get: %q", v)
+	}
+
+	if err := b.Set(ctx, "consent:ver", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, _ := a.Get(ctx, "consent:ver"); v == "2" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replica a still serves a stale L1 value")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTieredCache_ServesHotKeysFromL1(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	tc := newTieredT(t, mr)
+
+	if err := tc.Set(ctx, "geo:1.2.3.4", "KZ"); err != nil {
+		t.Fatal(err)
+	}
+	before := mr.CommandCount()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if v, err := tc.Get(ctx, "geo:1.2.3.4"); err != nil || v != "KZ" {
+					t.Errorf("Get: %q, %v", v, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 200 чтений: промахи схлопнуты singleflight'ом, остальное — из L1
+	if n := mr.CommandCount() - before; n > 20 {
+		t.Fatalf("redis commands for 200 reads: %d", n)
+	}
+}
+
+func TestTieredCache_L1NeverOutlivesRedisTTL(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	clk := newFakeClock()
+	tc := newTieredT(t, mr, WithL1TTL(time.Hour),
+		WithL1(NewMemory(100, WithClock(clk.Now), WithJanitorInterval(0))))
+
+	if err := tc.SetWithTTL(ctx, "otp:1", "1234", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.Set(ctx, "geo:1", "KZ"); err != nil { // без TTL
+		t.Fatal(err)
+	}
+	if got, _ := tc.MGet(ctx, "otp:1", "geo:1"); len(got) != 2 { // заполняет L1
+		t.Fatalf("MGet: %v", got)
+	}
+
+	mr.FastForward(6 * time.Second)
+	clk.Advance(6 * time.Second)
+
+	if _, err := tc.Get(ctx, "otp:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key served from L1: %v", err)
+	}
+	if ok, _ := tc.Exists(ctx, "otp:1"); ok {
+		t.Fatal("Exists reports an expired key")
+	}
+
+	// ключ без TTL остаётся в L1 на L1TTL
+	if v, err := tc.l1.Get(ctx, "geo:1"); err != nil || v != "KZ" {
+		t.Fatalf("key without TTL not kept in L1: %q, %v", v, err)
+	}
+}
+
+func TestTieredCache_IgnoresOwnInvalidationEcho(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	tc := newTieredT(t, mr, WithL1TTL(time.Hour))
+
+	if err := tc.l1.Set(ctx, "geo:1", "KZ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.l1.Set(ctx, "marker", "x"); err != nil {
+		t.Fatal(err)
+	}
+	publish := func(src string, keys ...string) {
+		msg, _ := json.Marshal(invalidation{Src: src, Keys: keys})
+		mr.Publish(tc.channel, string(msg))
+	}
+	publish(tc.id, "geo:1")    // эхо своей записи
+	publish("other", "marker") // сообщения приходят по порядку: marker — после эха
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if ok, _ := tc.l1.Exists(ctx, "marker"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("invalidation from another replica not applied")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if ok, _ := tc.l1.Exists(ctx, "geo:1"); !ok {
+		t.Fatal("own invalidation echo dropped L1")
+	}
+}
@@ -1,16 +1,18 @@
 package config
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 )
 
 type OTP struct {
-	Credential     string   `env:"OTP_API_CREDENTIAL,required"`
-	CredentialTest string   `env:"OTP_API_CREDENTIAL_TEST,required"`
-	Salt           string   `env:"OTP_API_SALT,required"`
+	Credential     string   `env:"OTP_API_CREDENTIAL,required" secret:"true"`
+	CredentialTest string   `env:"OTP_API_CREDENTIAL_TEST,required" secret:"true"`
+	Salt           string   `env:"OTP_API_SALT,required" secret:"true"`
 	URL            string   `env:"OTP_API_URL,required"`
 	URLTest        string   `env:"OTP_API_URL_TEST,required"`
 	AllowedHosts   []string `env:"OTP_ALLOWED_REDIRECT_HOSTS,required" envSeparator:","`
@@ -19,7 +21,7 @@ type OTP struct {
 type Sender struct {
 	ApiUrl string `env:"SENDER_API_URL,required"`
 	User   string `env:"SENDER_API_USER,required"`
-	Pass   string `env:"SENDER_API_PASS,required"`
+	Pass   string `env:"SENDER_API_PASS,required" secret:"true"`
 	Name   string `env:"SENDER_API_NAME,required"`
 }
 
@@ -28,6 +30,8 @@ type Kafka struct {
 	Producer KfkProducer
 	Consumer KfkConsumer
 	Security KfkSecurity
+	Outbox   KfkOutbox
+	Admin    KfkAdmin
 }
 
 type KfkClient struct {
@@ -48,6 +52,15 @@ type KfkProducer struct {
 	RetryBackoffMs         int    `env:"KAFKA_PRODUCER_RETRY_BACKOFF_MS,required"`
 	Balancer               string `env:"KAFKA_PRODUCER_BALANCER,required"`
 	AllowAutoTopicCreation bool   `env:"KAFKA_ALLOW_AUTO_TOPIC_CREATION,required"`
+	// SMSSchemaVer — версия схемы SMS на запись; повышать после выкатки консьюмеров.
+	SMSSchemaVer           string `env:"KAFKA_PRODUCER_SMS_SCHEMA_VER"   envDefault:"1"`
+	EventSource            string `env:"KAFKA_PRODUCER_EVENT_SOURCE"     envDefault:"pay_flow_go"` // ce_source
+	// SpoolDir — каталог дискового спула на случай недоступности Kafka; "" — выключен.
+	SpoolDir            string        `env:"KAFKA_PRODUCER_SPOOL_DIR"             envDefault:""`
+	SpoolMaxBytes       int64         `env:"KAFKA_PRODUCER_SPOOL_MAX_BYTES"       envDefault:"268435456"`
+	SpoolReplayInterval time.Duration `env:"KAFKA_PRODUCER_SPOOL_REPLAY_INTERVAL" envDefault:"5s"`
+	// QueueSize — сколько сообщений Enqueue может держать в полёте; дальше — backpressure.
+	QueueSize           int           `env:"KAFKA_PRODUCER_QUEUE_SIZE"            envDefault:"1000"`
 }
 
 type KfkConsumer struct {
@@ -62,51 +75,123 @@ type KfkConsumer struct {
 	StartOffset					 string `env:"KAFKA_CONSUMER_OFFSET,required"`
 	BatchSize						 int  	`env:"KAFKA_CONSUMER_BATCH,required"`
 	TickMs 							 int  	`env:"KAFKA_CONSUMER_TICKS,required"`
+	DLQTopic             string `env:"KAFKA_CONSUMER_DLQ_TOPIC"        envDefault:""`
+	MaxAttempts          int    `env:"KAFKA_CONSUMER_MAX_ATTEMPTS"     envDefault:"3"`
+	// RetryDelays — задержки retry-тиров, напр. "30s,5m" → <topic>.retry.30s, <topic>.retry.5m.
+	RetryDelays []time.Duration `env:"KAFKA_CONSUMER_RETRY_DELAYS" envSeparator:"," envDefault:""`
+	// PartitionWorkers > 0 — батч делится по партициям и обрабатывается пулом воркеров.
+	PartitionWorkers     int    `env:"KAFKA_CONSUMER_PARTITION_WORKERS" envDefault:"0"`
+	DedupeTTL     time.Duration `env:"KAFKA_CONSUMER_DEDUPE_TTL"       envDefault:"24h"`
+	UnknownEvents        string `env:"KAFKA_CONSUMER_UNKNOWN_EVENTS"   envDefault:"skip"` // skip | dlq | fallback
+}
+
+type KfkOutbox struct {
+	BatchSize int           `env:"KAFKA_OUTBOX_BATCH"     envDefault:"100"`
+	Interval  time.Duration `env:"KAFKA_OUTBOX_INTERVAL"  envDefault:"500ms"`
+	Retention time.Duration `env:"KAFKA_OUTBOX_RETENTION" envDefault:"72h"` // сколько хранить отправленные
+}
+
+// KfkAdmin — декларация служебных и рабочих топиков.
+type KfkAdmin struct {
+	EnsureTopics      bool          `env:"KAFKA_ADMIN_ENSURE_TOPICS"      envDefault:"false"` // при старте server.New
+	Partitions        int           `env:"KAFKA_TOPIC_PARTITIONS"         envDefault:"3"`
+	ReplicationFactor int           `env:"KAFKA_TOPIC_REPLICATION_FACTOR" envDefault:"1"`
+	Retention         time.Duration `env:"KAFKA_TOPIC_RETENTION"          envDefault:"168h"`
+	CleanupPolicy     string        `env:"KAFKA_TOPIC_CLEANUP_POLICY"     envDefault:"delete"`
 }
 
 type KfkSecurity struct {
 	SASLEnable            bool   `env:"KAFKA_SASL_ENABLE,required"`
 	SASLMechanism         string `env:"KAFKA_SASL_MECHANISM"            envDefault:"SCRAM-SHA-256"`
 	SASLUsername          string `env:"KAFKA_SASL_USERNAME"`
-	SASLPassword          string `env:"KAFKA_SASL_PASSWORD"`
+	SASLPassword          string `env:"KAFKA_SASL_PASSWORD" secret:"true"`
 	TLSEnable             bool   `env:"KAFKA_TLS_ENABLE"                 envDefault:"false"`
 	TLSInsecureSkipVerify bool   `env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"   envDefault:"false"`
 }
 
+// Telemetry — ресурс и семплирование OTel. Стандартные OTEL_RESOURCE_ATTRIBUTES,
+// OTEL_SERVICE_NAME и OTEL_TRACES_SAMPLER(_ARG) имеют приоритет.
+type Telemetry struct {
+	ServiceName      string  `env:"TELEMETRY_SERVICE_NAME"      envDefault:"pay_flow_go"`
+	ServiceNamespace string  `env:"TELEMETRY_SERVICE_NAMESPACE" envDefault:"payments"`
+	ServiceVersion   string  `env:"TELEMETRY_SERVICE_VERSION"   envDefault:"dev"`
+	InstanceID       string  `env:"TELEMETRY_INSTANCE_ID"       envDefault:""` // "" — hostname
+	Sampler          string  `env:"TELEMETRY_SAMPLER"           envDefault:"parentbased_always_on"`
+	SamplerRatio     float64 `env:"TELEMETRY_SAMPLER_RATIO"     envDefault:"1"` // для *traceidratio
+}
+
 type Config struct {
 	Env string `env:"ENV,required"`
 
 	Port          int    `env:"APP_PORT,required"`
 	AppCpus       string `env:"APP_CPUS,required"`
-	RedisUrl      string `env:"REDIS_URL,required"`
-	RedisUrlLocal string `env:"REDIS_URL_LOCAL,required"`
+	RedisUrl      string `env:"REDIS_URL,required" secret:"url"`
+	RedisUrlLocal string `env:"REDIS_URL_LOCAL,required" secret:"url"`
 
-	SecretKeyBase     string `env:"SECRET_KEY_BASE,required"`
+	SecretKeyBase     string `env:"SECRET_KEY_BASE,required" secret:"true"`
 	AppBaseURL        string `env:"APP_BASE_URL,required"`
 	AppBasePath       string `env:"APP_BASE_PATH,required"`
 	WithGeocoder      bool   `env:"WITH_GEOCODER,required"`
 	Location          string `env:"LOCATION,required"`
 	LogLevel          int    `env:"LOG_LEVEL,required"`
+	LogFormat         string `env:"LOG_FORMAT" envDefault:"console"` // console | json
+	PIIMasking        string `env:"PII_MASKING" envDefault:"mask"`  // mask | full | off (только dev)
 	TelemetryEndpoint string `env:"TELEMETRY_ENDPOINT,required"`
-	IPInfoToken       string `env:"IPINFO_TOKEN,required"`
+	IPInfoToken       string `env:"IPINFO_TOKEN,required" secret:"true"`
 	ConsentPath       string `env:"CONSENT_PATH,required"`
 
-	OTP    OTP
-	Sender Sender
-	Kafka  Kafka
+	OTP       OTP
+	Sender    Sender
+	Kafka     Kafka
+	Telemetry Telemetry
 }
 
+// Load читает конфиг из окружения (и .env). Секреты можно передать
+// файлами (<NAME>_FILE) или через провайдеры по умолчанию: SECRETS_DIR
+// и зашифрованный SECRETS_FILE (см. defaultProviders).
 func Load() (*Config, error) {
+	loadDotenv()
+
+	environ := environMap()
+	providers, err := defaultProviders(environ)
+	if err != nil {
+		return nil, err
+	}
+	return load(environ, providers)
+}
+
+// LoadWith — Load с явным набором провайдеров секретов (по порядку).
+func LoadWith(providers ...SecretProvider) (*Config, error) {
+	loadDotenv()
+	return load(environMap(), providers)
+}
+
+// startupEnv — окружение процесса до godotenv.Load; nil — Load не вызывали.
+var startupEnv map[string]string
+
+// loadDotenv дописывает .env в окружение; заданное окружением не перекрывается.
+func loadDotenv() {
+	startupEnv = environMap()
 	_ = godotenv.Load()
+}
+
+func load(environ map[string]string, providers []SecretProvider) (*Config, error) {
+	if err := resolveSecrets(environ, providers); err != nil {
+		return nil, err
+	}
 
 	var c Config
 	if err := env.ParseWithOptions(&c, env.Options{
+		Environment:     environ,
 		RequiredIfNoDef: true,
 	}); err != nil {
 		return nil, err
 	}
 
 	c.RedisUrl = redisURL(&c)
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
 	return &c, nil
 }
 
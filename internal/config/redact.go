@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const redacted = "***"
+
+// String — конфиг в формате %+v, но с замаскированными полями `secret:"..."`:
+// "true" — значение целиком, "url" — только пароль в URL.
+func (c Config) String() string {
+	type plain Config // без метода String, иначе рекурсия
+	return fmt.Sprintf("%+v", plain(c.Redacted()))
+}
+
+// MarshalZerologObject — log.Info().Object("config", cfg) без секретов.
+func (c Config) MarshalZerologObject(e *zerolog.Event) {
+	r := c.Redacted()
+	marshalStruct(e, reflect.ValueOf(&r).Elem())
+}
+
+// Redacted — копия конфига с замаскированными секретами (для логов и
+// admin-эндпоинтов).
+func (c Config) Redacted() Config {
+	redactStruct(reflect.ValueOf(&c).Elem())
+	return c
+}
+
+func redactStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f, sf := v.Field(i), t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if f.Kind() == reflect.Struct {
+			redactStruct(f)
+			continue
+		}
+		mode, ok := sf.Tag.Lookup("secret")
+		if !ok || f.Kind() != reflect.String || f.String() == "" {
+			continue
+		}
+		switch mode {
+		case "url":
+			f.SetString(redactURL(f.String()))
+		default:
+			f.SetString(redacted)
+		}
+	}
+}
+
+// redactURL скрывает пароль; неразбираемый URL скрывается целиком.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
+
+func marshalStruct(e *zerolog.Event, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f, sf := v.Field(i), t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if f.Kind() == reflect.Struct {
+			d := zerolog.Dict()
+			marshalStruct(d, f)
+			e.Dict(sf.Name, d)
+			continue
+		}
+		if d, ok := f.Interface().(time.Duration); ok {
+			e.Str(sf.Name, d.String())
+			continue
+		}
+		e.Interface(sf.Name, f.Interface())
+	}
+}
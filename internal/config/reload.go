@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+)
+
+// Runtime — подмножество конфига, которое можно менять без рестарта.
+// Остальные поля при перезагрузке игнорируются.
+type Runtime struct {
+	LogLevel      int
+	ConsumerBatch int
+	ConsumerTick  time.Duration
+	AllowedHosts  []string
+}
+
+func (c *Config) Runtime() Runtime {
+	return Runtime{
+		LogLevel:      c.LogLevel,
+		ConsumerBatch: c.Kafka.Consumer.BatchSize,
+		ConsumerTick:  time.Duration(c.Kafka.Consumer.TickMs) * time.Millisecond,
+		AllowedHosts:  slices.Clone(c.OTP.AllowedHosts),
+	}
+}
+
+func (r Runtime) equal(o Runtime) bool {
+	return r.LogLevel == o.LogLevel && r.ConsumerBatch == o.ConsumerBatch &&
+		r.ConsumerTick == o.ConsumerTick && slices.Equal(r.AllowedHosts, o.AllowedHosts)
+}
+
+// Reloader перечитывает конфиг по SIGHUP или при изменении файла,
+// валидирует его целиком и только потом атомарно публикует новый Runtime
+// подписчикам. Невалидный конфиг не применяется — остаётся прежний.
+//
+// Сам Reloader ничего не применяет: это точка подписки для того, кто
+// строит компонент (консьюмер — через Consumer.Tune, проверку редиректа —
+// через RedirectAllowed). В cmd сейчас подписан только уровень логов.
+type Reloader struct {
+	path string // .env-файл, значения которого перекрывают окружение
+	cur  atomic.Pointer[Runtime]
+
+	mu   sync.Mutex // сериализует Reload и вызовы подписчиков
+	subs []func(Runtime)
+
+	provMu    sync.Mutex
+	provKey   string // переменные, из которых собраны providers
+	providers []SecretProvider
+}
+
+// NewReloader — cfg — уже загруженный конфиг, path — файл для перечитывания
+// (обычно ".env"; "" — только окружение процесса).
+func NewReloader(cfg *Config, path string) *Reloader {
+	r := &Reloader{path: path}
+	rt := cfg.Runtime()
+	r.cur.Store(&rt)
+	return r
+}
+
+// Current — действующий Runtime; безопасно из любой горутины.
+func (r *Reloader) Current() Runtime { return *r.cur.Load() }
+
+// Subscribe добавляет подписчика: он вызывается сразу с текущим Runtime
+// и затем после каждого применённого изменения.
+func (r *Reloader) Subscribe(fn func(Runtime)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs = append(r.subs, fn)
+	fn(r.Current())
+}
+
+// RedirectAllowed — входит ли host в OTP_ALLOWED_REDIRECT_HOSTS.
+func (r *Reloader) RedirectAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSpace(host))
+	for _, h := range r.cur.Load().AllowedHosts {
+		if strings.ToLower(strings.TrimSpace(h)) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload перечитывает конфиг и применяет его, если он валиден и Runtime изменился.
+func (r *Reloader) Reload() error {
+	environ, err := r.environ()
+	if err != nil {
+		return err
+	}
+	providers, err := r.secretProviders(environ)
+	if err != nil {
+		return err
+	}
+	c, err := load(environ, providers)
+	if err != nil {
+		return err
+	}
+	r.apply(c.Runtime())
+	return nil
+}
+
+// environ — окружение для перезагрузки с тем же приоритетом, что и на
+// старте: окружение процесса (до godotenv.Load) важнее файла. Берётся
+// исходное окружение, а не текущее: иначе значения, пришедшие из старого
+// .env, пережили бы их удаление из файла.
+func (r *Reloader) environ() (map[string]string, error) {
+	environ := maps.Clone(startupEnv)
+	if environ == nil {
+		environ = environMap()
+	}
+	if r.path == "" {
+		return environ, nil
+	}
+	file, err := godotenv.Read(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for k, v := range file {
+		if _, set := environ[k]; !set {
+			environ[k] = v
+		}
+	}
+	return environ, nil
+}
+
+// secretProviders — defaultProviders, собранные заново, только если
+// изменились SECRETS_*: иначе каждая перезагрузка заново читала бы и
+// расшифровывала SECRETS_FILE.
+func (r *Reloader) secretProviders(environ map[string]string) ([]SecretProvider, error) {
+	key := strings.Join([]string{
+		environ["SECRETS_FILE"], environ["SECRETS_KEY"], environ["SECRETS_KEY_FILE"], environ["SECRETS_DIR"],
+	}, "\x00")
+
+	r.provMu.Lock()
+	defer r.provMu.Unlock()
+	if r.providers != nil && key == r.provKey {
+		return r.providers, nil
+	}
+	providers, err := defaultProviders(environ)
+	if err != nil {
+		return nil, err
+	}
+	r.provKey, r.providers = key, providers
+	return providers, nil
+}
+
+func (r *Reloader) apply(rt Runtime) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rt.equal(r.Current()) {
+		return
+	}
+	r.cur.Store(&rt)
+	for _, fn := range r.subs {
+		fn(rt)
+	}
+	log.Info().Int("log_level", rt.LogLevel).Int("consumer_batch", rt.ConsumerBatch).
+		Dur("consumer_tick", rt.ConsumerTick).Strs("allowed_hosts", rt.AllowedHosts).Msg("runtime config reloaded")
+}
+
+// Watch перезагружает конфиг по SIGHUP и при изменении mtime файла
+// (опрос раз в interval). Блокирует до отмены ctx.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	mtime := r.modTime()
+
+	reload := func(reason string) {
+		if err := r.Reload(); err != nil {
+			log.Error().Err(err).Str("trigger", reason).Msg("config reload rejected; keeping current")
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload("sighup")
+		case <-ticker.C:
+			if m := r.modTime(); !m.Equal(mtime) {
+				mtime = m
+				reload("file")
+			}
+		}
+	}
+}
+
+func (r *Reloader) modTime() time.Time {
+	if r.path == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
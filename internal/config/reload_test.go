@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloader_NotifiesOnChangeOnly(t *testing.T) {
+	cfg := validConfig()
+	cfg.OTP.AllowedHosts = []string{"pay.example.kz"}
+	r := NewReloader(cfg, "")
+
+	var got []Runtime
+	r.Subscribe(func(rt Runtime) { got = append(got, rt) })
+	if len(got) != 1 || got[0].ConsumerTick != 200*time.Millisecond {
+		t.Fatalf("initial notification: %+v", got)
+	}
+
+	next := cfg.Runtime()
+	next.ConsumerBatch = 500
+	next.AllowedHosts = []string{"new.example.kz"}
+	r.apply(next)
+	r.apply(next) // без изменений — без уведомления
+
+	if len(got) != 2 || got[1].ConsumerBatch != 500 {
+		t.Fatalf("notifications: %+v", got)
+	}
+	if r.RedirectAllowed("pay.example.kz") || !r.RedirectAllowed("NEW.example.kz") {
+		t.Fatal("allowlist not swapped")
+	}
+}
+
+func TestReloader_InvalidConfigKeepsCurrent(t *testing.T) {
+	cfg := validConfig()
+	r := NewReloader(cfg, writeFile(t, t.TempDir(), ".env", "KAFKA_CONSUMER_TICKS=0\n"))
+	t.Setenv("SECRETS_DIR", filepath.Join(t.TempDir(), "none"))
+
+	calls := 0
+	r.Subscribe(func(Runtime) { calls++ })
+
+	if err := r.Reload(); err == nil {
+		t.Fatal("reload of an invalid config must fail")
+	}
+	if calls != 1 || r.Current().ConsumerTick != 200*time.Millisecond {
+		t.Fatalf("invalid config applied: calls=%d current=%+v", calls, r.Current())
+	}
+}
+
+func TestReloader_SecretProvidersCachedUntilSecretsEnvChanges(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	enc, err := EncryptSecrets(key, map[string]string{"OTP_API_SALT": "salt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encPath := writeFile(t, t.TempDir(), "secrets.enc", string(enc))
+	environ := map[string]string{
+		"SECRETS_FILE": encPath,
+		"SECRETS_KEY":  base64.StdEncoding.EncodeToString(key),
+		"SECRETS_DIR":  t.TempDir(),
+	}
+	r := NewReloader(validConfig(), "")
+
+	first, err := r.secretProviders(environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// файл больше не читается: провайдеры берутся из кеша
+	if err := os.Remove(encPath); err != nil {
+		t.Fatal(err)
+	}
+	again, err := r.secretProviders(environ)
+	if err != nil || &again[0] != &first[0] {
+		t.Fatalf("providers rebuilt without SECRETS_* change: %v", err)
+	}
+
+	environ["SECRETS_DIR"] = t.TempDir()
+	if _, err := r.secretProviders(environ); err == nil {
+		t.Fatal("SECRETS_* change must rebuild providers")
+	}
+}
+
+func TestReloader_EnvironKeepsStartupPrecedence(t *testing.T) {
+	prev := startupEnv
+	t.Cleanup(func() { startupEnv = prev })
+	startupEnv = map[string]string{"LOG_LEVEL": "1"}
+
+	dir := t.TempDir()
+	path := writeFile(t, dir, ".env", "LOG_LEVEL=3\nKAFKA_CONSUMER_BATCH=500\n")
+	r := NewReloader(validConfig(), path)
+
+	env, err := r.environ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["LOG_LEVEL"] != "1" || env["KAFKA_CONSUMER_BATCH"] != "500" {
+		t.Fatalf("environ: LOG_LEVEL=%q KAFKA_CONSUMER_BATCH=%q", env["LOG_LEVEL"], env["KAFKA_CONSUMER_BATCH"])
+	}
+
+	// ключ, удалённый из .env, не остаётся со старым значением
+	writeFile(t, dir, ".env", "LOG_LEVEL=3\n")
+	if env, _ = r.environ(); env["KAFKA_CONSUMER_BATCH"] != "" {
+		t.Fatalf("removed key kept: %q", env["KAFKA_CONSUMER_BATCH"])
+	}
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// SecretProvider — источник секретов для Load. Значения попадают
+// только в конфиг, не в окружение процесса (и не в docker inspect).
+type SecretProvider interface {
+	// Secret возвращает значение переменной name; ok=false — у провайдера его нет.
+	Secret(name string) (value string, ok bool, err error)
+}
+
+// FileProvider — секреты файлами в каталоге, по файлу на переменную:
+// <Dir>/SECRET_KEY_BASE или <Dir>/secret_key_base (Docker/Kubernetes secrets).
+type FileProvider struct {
+	Dir string
+}
+
+func (p FileProvider) Secret(name string) (string, bool, error) {
+	for _, fn := range []string{name, strings.ToLower(name)} {
+		v, err := readSecretFile(filepath.Join(p.Dir, fn))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return v, true, nil
+	}
+	return "", false, nil
+}
+
+// EncryptedFileProvider — локальный файл с JSON {"NAME": "value"},
+// зашифрованный AES-256-GCM: nonce || ciphertext. Файл читается один раз.
+type EncryptedFileProvider struct {
+	values map[string]string
+}
+
+// NewEncryptedFileProvider расшифровывает path ключом key (32 байта).
+func NewEncryptedFileProvider(path string, key []byte) (*EncryptedFileProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets file %s: too short", path)
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("secrets file %s: %w", path, err)
+	}
+	p := &EncryptedFileProvider{}
+	if err := json.Unmarshal(plain, &p.values); err != nil {
+		return nil, fmt.Errorf("secrets file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *EncryptedFileProvider) Secret(name string) (string, bool, error) {
+	v, ok := p.values[name]
+	return v, ok, nil
+}
+
+// EncryptSecrets — содержимое файла для EncryptedFileProvider.
+func EncryptSecrets(key []byte, values map[string]string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// defaultProviders — из окружения: SECRETS_DIR (по умолчанию /run/secrets)
+// и, если задан, SECRETS_FILE с ключом SECRETS_KEY(_FILE) в base64.
+func defaultProviders(environ map[string]string) ([]SecretProvider, error) {
+	var out []SecretProvider
+
+	if path := environ["SECRETS_FILE"]; path != "" {
+		key, err := lookupSecret(environ, "SECRETS_KEY")
+		if err != nil {
+			return nil, err
+		}
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("SECRETS_KEY: %w", err)
+		}
+		p, err := NewEncryptedFileProvider(path, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+
+	dir := environ["SECRETS_DIR"]
+	if dir == "" {
+		dir = "/run/secrets"
+	}
+	return append(out, FileProvider{Dir: dir}), nil
+}
+
+// resolveSecrets дописывает в environ значения секретных переменных
+// (поля с тегом secret). Порядок: <NAME>_FILE, сама <NAME>, провайдеры.
+func resolveSecrets(environ map[string]string, providers []SecretProvider) error {
+	var errs []error
+	for _, name := range secretEnvNames(reflect.TypeOf(Config{})) {
+		if _, set := environ[name]; set && environ[name+"_FILE"] == "" {
+			continue
+		}
+		v, err := lookupSecret(environ, name)
+		if err == nil {
+			environ[name] = v
+			continue
+		}
+		if !errors.Is(err, errSecretNotSet) {
+			errs = append(errs, err)
+			continue
+		}
+		for _, p := range providers {
+			v, ok, err := p.Secret(name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				break
+			}
+			if ok {
+				environ[name] = v
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var errSecretNotSet = errors.New("secret not set")
+
+// lookupSecret читает <name>_FILE; задавать одновременно и name — ошибка.
+func lookupSecret(environ map[string]string, name string) (string, error) {
+	path := environ[name+"_FILE"]
+	if path == "" {
+		if v, ok := environ[name]; ok {
+			return v, nil
+		}
+		return "", errSecretNotSet
+	}
+	if _, both := environ[name]; both {
+		return "", fmt.Errorf("%s and %s_FILE are both set", name, name)
+	}
+	v, err := readSecretFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	return v, nil
+}
+
+// readSecretFile — содержимое без завершающего перевода строки.
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// secretEnvNames — имена переменных окружения полей с тегом secret.
+func secretEnvNames(t reflect.Type) []string {
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, secretEnvNames(f.Type)...)
+			continue
+		}
+		if _, ok := f.Tag.Lookup("secret"); !ok {
+			continue
+		}
+		if name, _, _ := strings.Cut(f.Tag.Get("env"), ","); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// environMap — окружение процесса в виде map для env.Options.Environment.
+func environMap() map[string]string {
+	out := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
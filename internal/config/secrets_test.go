@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestResolveSecrets_FileVariantAndProviders(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{7}, 32)
+	enc, err := EncryptSecrets(key, map[string]string{"OTP_API_SALT": "salt-from-vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encPath := writeFile(t, dir, "secrets.enc", string(enc))
+	vault, err := NewEncryptedFileProvider(encPath, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := t.TempDir()
+	writeFile(t, mounts, "sender_api_pass", "pass-from-mount\n")
+
+	environ := map[string]string{
+		"SECRET_KEY_BASE_FILE": writeFile(t, dir, "skb", "skb-from-file\n"),
+		"IPINFO_TOKEN":         "token-from-env",
+	}
+	if err := resolveSecrets(environ, []SecretProvider{vault, FileProvider{Dir: mounts}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"SECRET_KEY_BASE": "skb-from-file",
+		"IPINFO_TOKEN":    "token-from-env",
+		"OTP_API_SALT":    "salt-from-vault",
+		"SENDER_API_PASS": "pass-from-mount",
+	}
+	for k, v := range want {
+		if environ[k] != v {
+			t.Errorf("%s = %q, want %q", k, environ[k], v)
+		}
+	}
+	if _, leaked := os.LookupEnv("SECRET_KEY_BASE"); leaked {
+		t.Fatal("secret must not be exported to the process environment")
+	}
+}
+
+func TestResolveSecrets_BothSetIsError(t *testing.T) {
+	environ := map[string]string{
+		"KAFKA_SASL_PASSWORD":      "a",
+		"KAFKA_SASL_PASSWORD_FILE": writeFile(t, t.TempDir(), "p", "b"),
+	}
+	err := resolveSecrets(environ, nil)
+	if err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Fatalf("want both-set error, got %v", err)
+	}
+}
+
+func TestEncryptedFileProvider_WrongKey(t *testing.T) {
+	enc, err := EncryptSecrets(bytes.Repeat([]byte{1}, 32), map[string]string{"X": "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := writeFile(t, t.TempDir(), "s.enc", string(enc))
+	if _, err := NewEncryptedFileProvider(p, bytes.Repeat([]byte{2}, 32)); err == nil {
+		t.Fatal("decrypting with a wrong key must fail")
+	}
+}
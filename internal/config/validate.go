@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validate проверяет значения, а не только их наличие: перечисления,
+// диапазоны, URL и связи между полями. Возвращает все проблемы сразу
+// (errors.Join), по одной строке на переменную окружения.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(c.Env != "", "ENV", "must not be empty")
+	v.check(c.Port > 0 && c.Port <= 65535, "APP_PORT", "must be in 1..65535, got %d", c.Port)
+	v.check(c.LogLevel >= -1 && c.LogLevel <= 7, "LOG_LEVEL", "must be in -1..7 (zerolog levels), got %d", c.LogLevel)
+	v.oneOf("LOG_FORMAT", c.LogFormat, "console", "json")
+	v.oneOf("PII_MASKING", c.PIIMasking, "mask", "full", "off") // те же значения, что у pii.ParseMode
+	if strings.EqualFold(strings.TrimSpace(c.Env), "production") {
+		v.check(!strings.EqualFold(strings.TrimSpace(c.PIIMasking), "off"), "PII_MASKING", "off is for local development only, not allowed with ENV=production")
+	}
+	// LOCATION не проверяем: без tzdata в образе logger.Init сам откатывается на UTC+6
+
+	v.url("APP_BASE_URL", c.AppBaseURL, "http", "https")
+	v.url("TELEMETRY_ENDPOINT", c.TelemetryEndpoint, "http", "https")
+	v.redisDSN("REDIS_URL", c.RedisUrl)
+	v.url("OTP_API_URL", c.OTP.URL, "http", "https")
+	v.url("OTP_API_URL_TEST", c.OTP.URLTest, "http", "https")
+	v.url("SENDER_API_URL", c.Sender.ApiUrl, "http", "https")
+
+	c.Kafka.validate(v)
+	c.Telemetry.validate(v)
+
+	return v.err()
+}
+
+func (k *Kafka) validate(v *validator) {
+	servers := 0
+	for _, s := range k.Client.BootstrapServers {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		servers++
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			v.add("KAFKA_BOOTSTRAP_SERVERS", "%q is not host:port", s)
+		}
+	}
+	v.check(servers > 0, "KAFKA_BOOTSTRAP_SERVERS", "must list at least one broker")
+	v.check(strings.TrimSpace(k.Client.ConsumerTopic) != "", "KAFKA_CONSUMER_TOPIC", "must not be empty")
+
+	p := k.Producer
+	v.oneOf("KAFKA_PRODUCER_REQUIRED_ACKS", p.RequiredAcks, "none", "leader", "one", "all", "0", "1", "-1")
+	v.oneOf("KAFKA_PRODUCER_COMPRESSION", p.Compression, "gzip", "lz4", "zstd", "snappy")
+	v.oneOf("KAFKA_PRODUCER_BALANCER", p.Balancer, "least-bytes", "leastbytes", "round-robin", "roundrobin", "crc32", "murmur2")
+	v.check(p.BatchBytes >= 0, "KAFKA_PRODUCER_BATCH_BYTES", "must not be negative")
+	v.check(p.Retries >= 0, "KAFKA_PRODUCER_RETRIES", "must not be negative")
+	v.check(p.QueueSize > 0, "KAFKA_PRODUCER_QUEUE_SIZE", "must be positive, got %d", p.QueueSize)
+	v.check(p.SpoolMaxBytes >= 0, "KAFKA_PRODUCER_SPOOL_MAX_BYTES", "must not be negative")
+	if strings.TrimSpace(p.SpoolDir) != "" {
+		v.check(p.SpoolReplayInterval > 0, "KAFKA_PRODUCER_SPOOL_REPLAY_INTERVAL", "must be positive when the spool is enabled")
+	}
+
+	c := k.Consumer
+	v.check(c.BatchSize > 0, "KAFKA_CONSUMER_BATCH", "must be positive, got %d", c.BatchSize)
+	v.check(c.TickMs > 0, "KAFKA_CONSUMER_TICKS", "must be positive, got %d", c.TickMs)
+	v.check(c.MaxWaitMs > 0, "KAFKA_CONSUMER_MAX_WAIT_MS", "must be positive, got %d", c.MaxWaitMs)
+	v.check(c.MinBytes >= 0 && c.MinBytes <= c.MaxBytes, "KAFKA_CONSUMER_MIN_BYTES",
+		"must be in 0..KAFKA_CONSUMER_MAX_BYTES (%d), got %d", c.MaxBytes, c.MinBytes)
+	v.check(c.MaxAttempts >= 1, "KAFKA_CONSUMER_MAX_ATTEMPTS", "must be at least 1, got %d", c.MaxAttempts)
+	v.check(c.PartitionWorkers >= 0, "KAFKA_CONSUMER_PARTITION_WORKERS", "must not be negative")
+	v.check(c.DedupeTTL > 0, "KAFKA_CONSUMER_DEDUPE_TTL", "must be positive")
+	v.oneOf("KAFKA_CONSUMER_OFFSET", c.StartOffset, "earliest", "first", "latest", "last")
+	v.oneOf("KAFKA_CONSUMER_UNKNOWN_EVENTS", c.UnknownEvents, "skip", "dlq", "fallback")
+	if c.AutoCommit {
+		v.check(c.AutoCommitIntervalMs > 0, "KAFKA_CONSUMER_AUTO_COMMIT_INTERVAL_MS", "must be positive when auto commit is on")
+	}
+	for _, d := range c.RetryDelays {
+		v.check(d > 0, "KAFKA_CONSUMER_RETRY_DELAYS", "delays must be positive, got %s", d)
+	}
+	// после последнего retry-тира сообщение уходит в DLQ; без него партиция встанет
+	if len(c.RetryDelays) > 0 {
+		v.check(strings.TrimSpace(c.DLQTopic) != "", "KAFKA_CONSUMER_DLQ_TOPIC", "is required when KAFKA_CONSUMER_RETRY_DELAYS is set")
+	}
+	if strings.EqualFold(strings.TrimSpace(c.UnknownEvents), "dlq") {
+		v.check(strings.TrimSpace(c.DLQTopic) != "", "KAFKA_CONSUMER_DLQ_TOPIC", "is required when KAFKA_CONSUMER_UNKNOWN_EVENTS=dlq")
+	}
+	if dlq := strings.TrimSpace(c.DLQTopic); dlq != "" {
+		v.check(dlq != strings.TrimSpace(k.Client.ConsumerTopic), "KAFKA_CONSUMER_DLQ_TOPIC", "must differ from KAFKA_CONSUMER_TOPIC")
+	}
+
+	v.check(k.Outbox.BatchSize > 0, "KAFKA_OUTBOX_BATCH", "must be positive, got %d", k.Outbox.BatchSize)
+	v.check(k.Outbox.Interval > 0, "KAFKA_OUTBOX_INTERVAL", "must be positive")
+
+	v.check(k.Admin.Partitions >= 1, "KAFKA_TOPIC_PARTITIONS", "must be at least 1, got %d", k.Admin.Partitions)
+	v.check(k.Admin.ReplicationFactor >= 1, "KAFKA_TOPIC_REPLICATION_FACTOR", "must be at least 1, got %d", k.Admin.ReplicationFactor)
+	v.oneOf("KAFKA_TOPIC_CLEANUP_POLICY", k.Admin.CleanupPolicy, "delete", "compact", "compact,delete", "delete,compact")
+
+	s := k.Security
+	if s.SASLEnable {
+		v.oneOf("KAFKA_SASL_MECHANISM", strings.ToLower(s.SASLMechanism), "plain", "scram-sha-256", "scram-sha-512")
+		v.check(s.SASLUsername != "", "KAFKA_SASL_USERNAME", "is required when KAFKA_SASL_ENABLE=true")
+		v.check(s.SASLPassword != "", "KAFKA_SASL_PASSWORD", "is required when KAFKA_SASL_ENABLE=true")
+	}
+	if s.TLSInsecureSkipVerify {
+		v.check(s.TLSEnable, "KAFKA_TLS_INSECURE_SKIP_VERIFY", "has no effect without KAFKA_TLS_ENABLE=true")
+	}
+}
+
+func (t *Telemetry) validate(v *validator) {
+	v.check(strings.TrimSpace(t.ServiceName) != "", "TELEMETRY_SERVICE_NAME", "must not be empty")
+	v.oneOf("TELEMETRY_SAMPLER", t.Sampler, "always_on", "always_off", "traceidratio",
+		"parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio")
+	v.check(t.SamplerRatio >= 0 && t.SamplerRatio <= 1, "TELEMETRY_SAMPLER_RATIO", "must be in 0..1, got %g", t.SamplerRatio)
+}
+
+// validator собирает ошибки, чтобы показать их все разом.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(env, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: "+format, append([]any{env}, args...)...))
+}
+
+func (v *validator) check(ok bool, env, format string, args ...any) {
+	if !ok {
+		v.add(env, format, args...)
+	}
+}
+
+func (v *validator) oneOf(env, value string, allowed ...string) {
+	if !slices.Contains(allowed, strings.ToLower(strings.TrimSpace(value))) {
+		v.add(env, "unknown value %q (want one of: %s)", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) url(env, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.add(env, "must be a %s URL, got %q", strings.Join(schemes, "/"), redactURL(value))
+	}
+}
+
+// redisDSN — как и cache.New, принимает и URL, и голый host:port.
+func (v *validator) redisDSN(env, value string) {
+	if strings.Contains(value, "://") {
+		v.url(env, value, "redis", "rediss")
+		return
+	}
+	if host, _, err := net.SplitHostPort(strings.TrimSpace(value)); err != nil || host == "" {
+		v.add(env, "must be host:port or a redis/rediss URL, got %q", value)
+	}
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
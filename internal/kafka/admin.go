@@ -0,0 +1,205 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// TopicSpec — желаемое состояние топика.
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Configs           map[string]string // retention.ms, cleanup.policy, ...
+}
+
+// DeclaredTopics — все топики, которые нужны сервису: продюсер, консьюмер,
+// retry-тиры и DLQ, с параметрами из KAFKA_TOPIC_*.
+func DeclaredTopics(cfg *config.Kafka) []TopicSpec {
+	a := cfg.Admin
+	configs := map[string]string{}
+	if a.Retention > 0 {
+		configs["retention.ms"] = strconv.FormatInt(a.Retention.Milliseconds(), 10)
+	}
+	if p := strings.TrimSpace(a.CleanupPolicy); p != "" {
+		configs["cleanup.policy"] = p
+	}
+
+	consumerTopic := strings.TrimSpace(cfg.Client.ConsumerTopic)
+	names := []string{strings.TrimSpace(cfg.Client.ProducerTopic), consumerTopic}
+	if consumerTopic != "" {
+		for _, d := range cfg.Consumer.RetryDelays {
+			names = append(names, retryTopic(consumerTopic, d))
+		}
+	}
+	names = append(names, strings.TrimSpace(cfg.Consumer.DLQTopic))
+
+	seen := map[string]struct{}{}
+	var out []TopicSpec
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		if _, dup := seen[n]; dup {
+			continue
+		}
+		seen[n] = struct{}{}
+		out = append(out, TopicSpec{
+			Name:              n,
+			Partitions:        maxInt(a.Partitions, 1),
+			ReplicationFactor: maxInt(a.ReplicationFactor, 1),
+			Configs:           configs,
+		})
+	}
+	return out
+}
+
+// Admin — создание топиков и проверка дрейфа от декларации.
+type Admin struct {
+	c *kafka.Client
+}
+
+func NewAdmin(cfg *config.Kafka) *Admin {
+	d := newDialer(cfg)
+	return &Admin{c: &kafka.Client{
+		Addr:    kafka.TCP(splitCSV(cfg.Client.BootstrapServers)...),
+		Timeout: 10 * time.Second,
+		Transport: &kafka.Transport{
+			TLS:  d.TLS,
+			SASL: d.SASLMechanism,
+		},
+	}}
+}
+
+// EnsureTopics создаёт недостающие топики и пишет warning на каждое
+// расхождение существующих с декларацией (партиции, RF, конфиги).
+// Существующие топики не меняет — это решение оператора.
+func (a *Admin) EnsureTopics(ctx context.Context, specs []TopicSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+
+	meta, err := a.c.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return err
+	}
+	existing := map[string]kafka.Topic{}
+	for _, t := range meta.Topics {
+		if t.Error == nil {
+			existing[t.Name] = t
+		} else if !errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+			return fmt.Errorf("metadata %s: %w", t.Name, t.Error)
+		}
+	}
+
+	var (
+		create  []kafka.TopicConfig
+		present []TopicSpec
+	)
+	for _, s := range specs {
+		t, ok := existing[s.Name]
+		if !ok {
+			create = append(create, topicConfig(s))
+			continue
+		}
+		present = append(present, s)
+		if len(t.Partitions) != s.Partitions {
+			log.Warn().Str("topic", s.Name).Int("declared", s.Partitions).Int("actual", len(t.Partitions)).
+				Msg("topic partitions drift")
+		}
+		if len(t.Partitions) > 0 && len(t.Partitions[0].Replicas) != s.ReplicationFactor {
+			log.Warn().Str("topic", s.Name).Int("declared", s.ReplicationFactor).Int("actual", len(t.Partitions[0].Replicas)).
+				Msg("topic replication factor drift")
+		}
+	}
+
+	var errs error
+	if len(create) > 0 {
+		res, err := a.c.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: create, ValidateOnly: false})
+		if err != nil {
+			return err
+		}
+		for name, err := range res.Errors {
+			switch {
+			case err == nil:
+				log.Info().Str("topic", name).Msg("topic created")
+			case errors.Is(err, kafka.TopicAlreadyExists):
+				// создан параллельно другой репликой
+			default:
+				errs = errors.Join(errs, fmt.Errorf("create topic %s: %w", name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs, a.checkConfigs(ctx, present))
+}
+
+// checkConfigs сравнивает topic-level конфиги существующих топиков.
+func (a *Admin) checkConfigs(ctx context.Context, specs []TopicSpec) error {
+	var res []kafka.DescribeConfigRequestResource
+	for _, s := range specs {
+		if len(s.Configs) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(s.Configs))
+		for k := range s.Configs {
+			keys = append(keys, k)
+		}
+		res = append(res, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: s.Name,
+			ConfigNames:  keys,
+		})
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	out, err := a.c.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: res})
+	if err != nil {
+		return err
+	}
+	declared := map[string]map[string]string{}
+	for _, s := range specs {
+		declared[s.Name] = s.Configs
+	}
+	for _, r := range out.Resources {
+		if r.Error != nil {
+			log.Warn().Err(r.Error).Str("topic", r.ResourceName).Msg("describe topic configs failed")
+			continue
+		}
+		for _, e := range r.ConfigEntries {
+			want, ok := declared[r.ResourceName][e.ConfigName]
+			if ok && want != e.ConfigValue {
+				log.Warn().Str("topic", r.ResourceName).Str("config", e.ConfigName).
+					Str("declared", want).Str("actual", e.ConfigValue).Msg("topic config drift")
+			}
+		}
+	}
+	return nil
+}
+
+func topicConfig(s TopicSpec) kafka.TopicConfig {
+	tc := kafka.TopicConfig{
+		Topic:             s.Name,
+		NumPartitions:     s.Partitions,
+		ReplicationFactor: s.ReplicationFactor,
+	}
+	for k, v := range s.Configs {
+		tc.ConfigEntries = append(tc.ConfigEntries, kafka.ConfigEntry{ConfigName: k, ConfigValue: v})
+	}
+	return tc
+}
@@ -0,0 +1,35 @@
+package kafkaio
+
+import (
+	"testing"
+	"time"
+
+	"pay_flow_go/internal/config"
+)
+
+func TestDeclaredTopics_IncludesTiersAndDLQOnce(t *testing.T) {
+	cfg := &config.Kafka{}
+	cfg.Client.ProducerTopic = "sms"
+	cfg.Client.ConsumerTopic = "sms" // продюсер и консьюмер на одном топике
+	cfg.Consumer.DLQTopic = "sms.dlq"
+	cfg.Consumer.RetryDelays = []time.Duration{30 * time.Second, 5 * time.Minute}
+	cfg.Admin = config.KfkAdmin{Partitions: 6, ReplicationFactor: 0, Retention: 2 * time.Hour, CleanupPolicy: "delete"}
+
+	specs := DeclaredTopics(cfg)
+
+	want := []string{"sms", "sms.retry.30s", "sms.retry.5m", "sms.dlq"}
+	if len(specs) != len(want) {
+		t.Fatalf("got %d topics, want %d: %+v", len(specs), len(want), specs)
+	}
+	for i, s := range specs {
+		if s.Name != want[i] {
+			t.Fatalf("topic[%d] = %q, want %q", i, s.Name, want[i])
+		}
+		if s.Partitions != 6 || s.ReplicationFactor != 1 {
+			t.Fatalf("%s: partitions=%d rf=%d", s.Name, s.Partitions, s.ReplicationFactor)
+		}
+		if s.Configs["retention.ms"] != "7200000" || s.Configs["cleanup.policy"] != "delete" {
+			t.Fatalf("%s: configs %+v", s.Name, s.Configs)
+		}
+	}
+}
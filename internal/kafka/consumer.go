@@ -3,34 +3,96 @@ package kafkaio
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"pay_flow_go/internal/config"
+	"pay_flow_go/internal/logger"
 
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // BatchItem — элемент батча. Поле commit используется как "токен"
 // для подтверждения (partition+offset); наружу его не выносим.
+// С Router'ом декодированное событие лежит в Event (для sms — ещё и в SMS).
 type BatchItem struct {
-	SMS    SMS
-	commit kafka.Message
+	SMS       SMS
+	Event     any
+	EventType string
+	SchemaVer string
+	commit    kafka.Message
+}
+
+// Value — сырое тело сообщения (для fallback-обработчиков).
+func (it BatchItem) Value() []byte { return it.commit.Value }
+
+// Context возвращает ctx с контекстом трейса продюсера этого сообщения
+// и его message-id — для дочерних span'ов и логов (logger.Ctx) по элементу.
+func (it BatchItem) Context(ctx context.Context) context.Context {
+	if id, ok := it.Header(HeaderMessageID); ok && id != "" {
+		ctx = logger.WithMessageID(ctx, id)
+	}
+	if sc := it.spanContext(); sc.IsValid() {
+		return trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+func (it BatchItem) spanContext() trace.SpanContext { return remoteSpan(it.commit) }
+
+// Header — значение заголовка сообщения.
+func (it BatchItem) Header(key string) (string, bool) { return headerValue(it.commit.Headers, key) }
+
+// Handler обрабатывает батч и возвращает индексы успешных элементов.
+type Handler func(context.Context, []BatchItem) ([]int, error)
+
+// reader — то, что Consumer берёт от *kafka.Reader (в тестах подменяется).
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
 }
 
 type Consumer struct {
-	r         *kafka.Reader
-	tp        string
-	batchSize int
-	tick      time.Duration
-	fetchWait time.Duration
+	r           reader
+	tp          string
+	batchSize   atomic.Int64 // меняются на лету через Tune
+	tick        atomic.Int64 // time.Duration
+	fetchWait   time.Duration
+	maxAttempts int
+	backoff     time.Duration // пауза между попытками переложить сообщение из retry-тира
+	workers     int           // 0 — весь батч обрабатывается одним вызовом handler
+	dlqTopic    string        // "" — DLQ выключен
+	tiers       []retryTier   // retry-тиры по возрастанию номера попытки
+	rp          *republisher  // nil — нет ни DLQ, ни retry-тиров
+	offsets     *offsetTracker
+	commitMu    sync.Mutex  // watermarks и CommitMessages — одним шагом, см. commitAcked
+	dedupe      DedupeStore // nil — без дедупликации
+	dedupeTTL   time.Duration
+	router      *Router       // nil — все сообщения декодируются как SMS
+	unknown     UnknownPolicy // для Router'а без своей политики
+	metrics     *consumerMetrics
+
+	heldMu sync.Mutex
+	held   []heldMsg // не удалось переложить в retry-тир/DLQ; см. hold
+}
+
+// heldMsg — сообщение, ждущее повторной публикации в служебный топик.
+type heldMsg struct {
+	m      kafka.Message
+	reason string
+	dlq    bool // сразу в DLQ, минуя retry-тиры (decode, unknown event)
 }
 
-func NewConsumer(cfg *config.Kafka) *Consumer {
+func NewConsumer(cfg *config.Kafka, opts ...ConsumerOption) *Consumer {
 	d := newDialer(cfg)
 	topic := strings.TrimSpace(cfg.Client.ConsumerTopic)
 
@@ -53,31 +115,95 @@ func NewConsumer(cfg *config.Kafka) *Consumer {
 	}
 	rc.StartOffset = parseStartOffset(cfg.Consumer.StartOffset)
 
-	return &Consumer{
-		r:         kafka.NewReader(rc),
-		tp:        topic,
-		batchSize: cfg.Consumer.BatchSize,
-		tick:      time.Duration(cfg.Consumer.TickMs) * time.Millisecond,
-		fetchWait: time.Duration(cfg.Consumer.MaxWaitMs) * time.Millisecond, // ожидание до 1-го сообщения в тик
+	r := kafka.NewReader(rc)
+	c := &Consumer{
+		r:           r,
+		tp:          topic,
+		fetchWait:   time.Duration(cfg.Consumer.MaxWaitMs) * time.Millisecond, // ожидание до 1-го сообщения в тик
+		maxAttempts: maxInt(cfg.Consumer.MaxAttempts, 1),
+		backoff:     time.Second,
+		workers:     cfg.Consumer.PartitionWorkers,
+		dlqTopic:    strings.TrimSpace(cfg.Consumer.DLQTopic),
+		offsets:     newOffsetTracker(),
+		dedupeTTL:   cfg.Consumer.DedupeTTL,
+		unknown:     parseUnknownPolicy(cfg.Consumer.UnknownEvents),
+		metrics:     newConsumerMetrics(otel.GetMeterProvider(), r),
 	}
+	c.Tune(cfg.Consumer.BatchSize, time.Duration(cfg.Consumer.TickMs)*time.Millisecond)
+	for _, opt := range opts {
+		opt(c)
+	}
+	for _, delay := range cfg.Consumer.RetryDelays {
+		trc := rc
+		trc.Topic = retryTopic(topic, delay)
+		trc.GroupID = retryTopic(rc.GroupID, delay)
+		trc.StartOffset = kafka.FirstOffset // новая группа не должна терять уже отложенное
+		c.tiers = append(c.tiers, retryTier{topic: trc.Topic, delay: delay, r: kafka.NewReader(trc)})
+	}
+	if c.dlqTopic != "" || len(c.tiers) > 0 {
+		c.rp = newRepublisher(cfg, d)
+	}
+	return c
 }
 
 // Start — неблокирующий запуск тикового чтения.
 // Раз в tick собирает батч и вызывает handler.
 // handler должен вернуть индексы успешно обработанных элементов (okIdx).
-func (c *Consumer) Start(ctx context.Context, handler func(context.Context, []BatchItem) ([]int, error)) {
+// Неуспешные элементы проходят retry-тиры (если настроены) и DLQ.
+func (c *Consumer) Start(ctx context.Context, handler Handler) {
+	handler = c.withDedupe(handler)
 	go c.run(ctx, handler)
+	for _, t := range c.tiers {
+		go c.runRetry(ctx, t, handler)
+	}
+}
+
+// StartRouter — Start, при котором сообщения декодируются и раздаются
+// обработчикам по event-type/schema-ver из r. Router, созданный с
+// политикой "", получает её из KAFKA_CONSUMER_UNKNOWN_EVENTS.
+func (c *Consumer) StartRouter(ctx context.Context, r *Router) {
+	if r.policy == "" {
+		r.policy = c.unknown
+	}
+	c.router = r
+	c.Start(ctx, r.Handle)
 }
 
-func (c *Consumer) run(ctx context.Context, handler func(context.Context, []BatchItem) ([]int, error)) {
-	ticker := time.NewTicker(c.tick)
+// Tune меняет размер батча и тик без перезапуска (hot reload конфига).
+// Новый тик вступает в силу со следующего срабатывания; неположительные
+// значения игнорируются. На config.Reloader подписывает тот, кто строит
+// консьюмер: rl.Subscribe(func(rt) { c.Tune(rt.ConsumerBatch, rt.ConsumerTick) }).
+func (c *Consumer) Tune(batchSize int, tick time.Duration) {
+	if batchSize > 0 {
+		c.batchSize.Store(int64(batchSize))
+	}
+	if tick > 0 {
+		c.tick.Store(int64(tick))
+	}
+}
+
+func (c *Consumer) run(ctx context.Context, handler Handler) {
+	tick := time.Duration(c.tick.Load())
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
+	process := func(items []BatchItem) { c.process(ctx, handler, items) }
+	var pool *partitionPool
+	if c.workers > 0 {
+		pool = newPartitionPool(ctx, c.workers, process)
+		defer pool.stop()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if d := time.Duration(c.tick.Load()); d != tick {
+				tick = d
+				ticker.Reset(tick)
+			}
+			c.retryHeld(ctx)
 			items, err := c.PollBatch(ctx, c.fetchWait)
 			if err != nil {
 				log.Error().Err(err).Msg("poll batch failed")
@@ -87,26 +213,54 @@ func (c *Consumer) run(ctx context.Context, handler func(context.Context, []Batc
 				continue
 			}
 
-			okIdx, err := handler(ctx, items)
-			if err != nil {
-				log.Error().Err(err).Msg("handler error")
-			}
-			if err := c.CommitContiguous(ctx, items, okIdx); err != nil {
-				log.Warn().Err(err).Msg("commit contiguous failed")
+			if pool != nil {
+				pool.dispatch(ctx, items)
+				continue
 			}
+			process(items)
 		}
 	}
 }
 
+// process — обработка одного батча: handler, повторы/DLQ и коммит
+// непрерывного префикса успешных.
+func (c *Consumer) process(ctx context.Context, handler Handler, items []BatchItem) {
+	spanCtx, span := startProcessSpan(ctx, c.tp, items)
+	started := time.Now()
+	okIdx, err := handler(spanCtx, items)
+	c.metrics.handled(ctx, c.tp, len(items), started, err)
+	if err != nil {
+		logger.Ctx(spanCtx).Error().Err(err).Msg("handler error")
+	}
+	okIdx, stuck := c.settle(spanCtx, handler, items, okIdx, err)
+	endSpan(span, err)
+	for _, h := range stuck {
+		c.hold(h)
+	}
+	if err := c.CommitContiguous(ctx, items, okIdx); err != nil {
+		log.Warn().Err(err).Msg("commit contiguous failed")
+	}
+}
+
 // PollBatch делает один "тик": пытается набрать до batchSize сообщений.
 // Для первого сообщения ждёт до fetchWait, затем больше не ждёт.
 func (c *Consumer) PollBatch(ctx context.Context, fetchWait time.Duration) ([]BatchItem, error) {
-	maxN := c.batchSize
+	maxN := int(c.batchSize.Load())
 	items := make([]BatchItem, 0, maxN)
 
 	firstDeadline, cancelFirst := context.WithTimeout(ctx, fetchWait)
 	defer cancelFirst()
 
+	skipped := false
+	defer func() {
+		// пропущенные сообщения уже подтверждены в трекере — двигаем watermark
+		if skipped {
+			if err := c.commitAcked(ctx); err != nil {
+				log.Warn().Err(err).Msg("commit skipped messages failed")
+			}
+		}
+	}()
+
 	waitCtx := firstDeadline
 	for len(items) < maxN {
 		m, err := c.r.FetchMessage(waitCtx)
@@ -121,29 +275,65 @@ func (c *Consumer) PollBatch(ctx context.Context, fetchWait time.Duration) ([]Ba
 
 		// после первого успешного чтения — больше не ждём
 		waitCtx = ctx
+		c.metrics.observeLag(m)
 
-		// опциональный фильтр по заголовку
-		if !isSMSMessage(m.Headers) {
-			if err := c.r.CommitMessages(ctx, m); err != nil {
-				log.Warn().Err(err).Msg("commit skipped non-sms failed")
-			}
+		// уже подтверждённое (повторная выборка после ребаланса) — не отдаём handler'у
+		if !c.offsets.track(m) {
+			c.metrics.skip(ctx, m.Topic, skipRedelivered)
 			continue
 		}
 
-		var sms SMS
-		if err := json.Unmarshal(m.Value, &sms); err != nil {
-			// битное сообщение: логируем и сдвигаем оффсет (в бою — лучше DLQ)
-			log.Error().Err(err).Msg("decode failed; committing to skip")
-			_ = c.r.CommitMessages(ctx, m)
+		// без Router'а — фильтр по заголовку
+		if c.router == nil && !isSMSMessage(m.Headers) {
+			c.metrics.skip(ctx, m.Topic, skipFiltered)
+			c.offsets.ack(m)
+			skipped = true
 			continue
 		}
 
-		items = append(items, BatchItem{SMS: sms, commit: m})
+		it, err := c.decode(m)
+		if errors.Is(err, errUnknownEvent) {
+			log.Debug().Err(err).Int("partition", m.Partition).Int64("offset", m.Offset).Msg("unknown event")
+			c.metrics.skip(ctx, m.Topic, skipUnknown)
+			if c.router.policy == UnknownDLQ && !c.deadLetter(ctx, m, err.Error()) {
+				c.hold(heldMsg{m: m, reason: err.Error(), dlq: true})
+				continue
+			}
+			c.offsets.ack(m)
+			skipped = true
+			continue
+		}
+		if err != nil {
+			// битное сообщение: оффсет сдвигаем, только когда оно легло в DLQ
+			log.Error().Err(err).Int("partition", m.Partition).Int64("offset", m.Offset).Msg("decode failed")
+			c.metrics.decodeFailed(ctx, m.Topic)
+			if c.deadLetter(ctx, m, "decode: "+err.Error()) {
+				c.offsets.ack(m)
+				skipped = true
+			} else {
+				c.hold(heldMsg{m: m, reason: "decode: " + err.Error(), dlq: true})
+			}
+			continue
+		}
+
+		items = append(items, it)
 	}
 
 	return items, nil
 }
 
+func (c *Consumer) decode(m kafka.Message) (BatchItem, error) {
+	if c.router != nil {
+		return c.router.decode(m)
+	}
+	et, ver := eventOf(m.Headers)
+	sms, err := DecodeSMS(ver, m.Value)
+	if err != nil {
+		return BatchItem{}, err
+	}
+	return BatchItem{SMS: sms, Event: sms, EventType: et, SchemaVer: ver, commit: m}, nil
+}
+
 // Commit — явный коммит набора элементов (если уверен, что все успешны).
 func (c *Consumer) Commit(ctx context.Context, batch []BatchItem) error {
 	if len(batch) == 0 {
@@ -153,62 +343,184 @@ func (c *Consumer) Commit(ctx context.Context, batch []BatchItem) error {
 	for _, it := range batch {
 		msgs = append(msgs, it.commit)
 	}
-	return c.r.CommitMessages(ctx, msgs...)
+	c.offsets.ack(msgs...)
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+	if err := c.r.CommitMessages(ctx, msgs...); err != nil {
+		c.metrics.commitFailed(ctx, c.tp)
+		return err
+	}
+	c.offsets.committed(msgs)
+	return nil
 }
 
 // CommitOne — коммит одного элемента.
 func (c *Consumer) CommitOne(ctx context.Context, it BatchItem) error {
-	return c.r.CommitMessages(ctx, it.commit)
+	return c.Commit(ctx, []BatchItem{it})
 }
 
-// CommitContiguous — коммитит максимум непрерывный префикс успешных
-// сообщений в КАЖДОЙ партиции (до первого неуспеха). Это сохраняет FIFO.
+// CommitContiguous — подтверждает успешные элементы батча и коммитит
+// в КАЖДОЙ партиции максимальный оффсет, до которого всё подтверждено
+// (с учётом подтверждений из прошлых тиков). Неуспешный элемент держит
+// watermark своей партиции, пока не будет обработан, переложен в
+// retry-тир или DLQ. Это сохраняет FIFO и не теряет успехи за "дыркой".
 func (c *Consumer) CommitContiguous(ctx context.Context, batch []BatchItem, okIdx []int) error {
 	if len(batch) == 0 || len(okIdx) == 0 {
 		return nil
 	}
-	ok := make(map[int]struct{}, len(okIdx))
+	msgs := make([]kafka.Message, 0, len(okIdx))
 	for _, i := range okIdx {
-		ok[i] = struct{}{}
+		if i >= 0 && i < len(batch) {
+			msgs = append(msgs, batch[i].commit)
+		}
 	}
+	c.offsets.ack(msgs...)
+	return c.commitAcked(ctx)
+}
+
+// commitAcked коммитит текущие watermark'и трекера. Вызывается из
+// воркеров, retryHeld и PollBatch одновременно; под commitMu, чтобы
+// watermark, снятый раньше, не закоммитился после более высокого.
+func (c *Consumer) commitAcked(ctx context.Context) error {
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
 
-	type partState struct {
-		blocked  bool
-		toCommit *kafka.Message // последний подряд успешный
+	msgs := c.offsets.watermarks()
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := c.r.CommitMessages(ctx, msgs...); err != nil {
+		c.metrics.commitFailed(ctx, c.tp)
+		return err
 	}
-	parts := map[int]*partState{}
+	c.offsets.committed(msgs)
+	return nil
+}
 
-	for i, it := range batch {
-		p := it.commit.Partition
-		st := parts[p]
-		if st == nil {
-			st = &partState{}
-			parts[p] = st
+// settle повторно отдаёт handler'у неуспешные элементы, пока суммарно
+// не наберётся maxAttempts попыток. Оставшиеся после этого уходят в
+// следующий retry-тир или DLQ и считаются обработанными — иначе они
+// навсегда блокируют партицию. Возвращает расширенный okIdx и те
+// сообщения, которые переложить не удалось (stuck): их оффсет коммитить нельзя.
+func (c *Consumer) settle(ctx context.Context, handler Handler, items []BatchItem, okIdx []int, herr error) ([]int, []heldMsg) {
+	failed := failedIdx(len(items), okIdx)
+
+	for attempt := 2; attempt <= c.maxAttempts && len(failed) > 0 && ctx.Err() == nil; attempt++ {
+		retry := make([]BatchItem, len(failed))
+		for i, idx := range failed {
+			retry[i] = items[idx]
 		}
-		if st.blocked {
-			continue
+
+		var retryOk []int
+		retryOk, herr = handler(ctx, retry)
+		if herr != nil {
+			log.Error().Err(herr).Int("attempt", attempt).Msg("handler error on retry")
 		}
-		if _, success := ok[i]; success {
-			m := it.commit
-			st.toCommit = &m
+		for _, i := range retryOk {
+			if i >= 0 && i < len(failed) {
+				okIdx = append(okIdx, failed[i])
+			}
+		}
+		failed = failedIdx(len(items), okIdx)
+	}
+
+	if c.rp == nil || len(failed) == 0 || ctx.Err() != nil {
+		return okIdx, nil
+	}
+
+	reason := fmt.Sprintf("handler: not acked after %d attempts", c.maxAttempts)
+	if herr != nil {
+		reason += ": " + herr.Error()
+	}
+	var stuck []heldMsg
+	for _, idx := range failed {
+		if c.reroute(ctx, items[idx].commit, reason) {
+			okIdx = append(okIdx, idx)
 		} else {
-			st.blocked = true
+			stuck = append(stuck, heldMsg{m: items[idx].commit, reason: reason})
 		}
 	}
+	return okIdx, stuck
+}
+
+// hold запоминает сообщение основного топика, которое не удалось
+// переложить в служебный топик. Его оффсет остаётся pending — watermark партиции стоит и ничего
+// за ним не коммитится, — а публикация повторяется в начале каждого тика
+// (retryHeld). Без DLQ повторять некуда: партиция стоит до рестарта,
+// после которого сообщение будет выбрано заново.
+func (c *Consumer) hold(h heldMsg) {
+	if c.dlqTopic == "" && (h.dlq || len(c.tiers) == 0) {
+		log.Error().Str("topic", h.m.Topic).Int("partition", h.m.Partition).Int64("offset", h.m.Offset).
+			Str("reason", h.reason).Msg("no DLQ configured; partition is held until restart")
+		return
+	}
+	c.heldMu.Lock()
+	c.held = append(c.held, h)
+	c.heldMu.Unlock()
+}
+
+// retryHeld повторяет публикацию отложенных сообщений по порядку до
+// первой неудачи; принятые подтверждаются и коммитятся.
+func (c *Consumer) retryHeld(ctx context.Context) {
+	c.heldMu.Lock()
+	held := c.held
+	c.held = nil
+	c.heldMu.Unlock()
 
-	msgs := make([]kafka.Message, 0, len(parts))
-	for _, st := range parts {
-		if st.toCommit != nil {
-			msgs = append(msgs, *st.toCommit)
+	done := 0
+	for _, h := range held {
+		var ok bool
+		if h.dlq {
+			ok = c.deadLetter(ctx, h.m, h.reason)
+		} else {
+			ok = c.reroute(ctx, h.m, h.reason)
 		}
+		if !ok {
+			break
+		}
+		c.offsets.ack(h.m)
+		done++
 	}
-	if len(msgs) == 0 {
-		return nil
+
+	if done < len(held) {
+		c.heldMu.Lock()
+		c.held = append(held[done:], c.held...)
+		c.heldMu.Unlock()
+	}
+	if done > 0 {
+		if err := c.commitAcked(ctx); err != nil {
+			log.Warn().Err(err).Msg("commit held messages failed")
+		}
 	}
-	return c.r.CommitMessages(ctx, msgs...)
 }
 
-func (c *Consumer) Close() error { return c.r.Close() }
+// deadLetter публикует m в DLQ. Возвращает true, если сообщение
+// действительно легло в DLQ и его оффсет можно коммитить.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, reason string) bool {
+	if c.dlqTopic == "" {
+		return false
+	}
+	err := c.rp.publish(ctx, c.dlqTopic, m, kafka.Header{Key: HeaderDLQReason, Value: []byte(reason)})
+	if err != nil {
+		log.Error().Err(err).Str("topic", m.Topic).Int("partition", m.Partition).Int64("offset", m.Offset).
+			Msg("dlq publish failed")
+		return false
+	}
+	log.Warn().Str("topic", m.Topic).Int("partition", m.Partition).Int64("offset", m.Offset).
+		Str("reason", reason).Msg("message dead-lettered")
+	return true
+}
+
+func (c *Consumer) Close() error {
+	err := c.r.Close()
+	for _, t := range c.tiers {
+		err = errors.Join(err, t.r.Close())
+	}
+	if c.rp != nil {
+		err = errors.Join(err, c.rp.close())
+	}
+	return err
+}
 
 // --- helpers ---
 
@@ -223,12 +535,26 @@ func parseStartOffset(s string) int64 {
 	}
 }
 
-func isSMSMessage(h []kafka.Header) bool {
-	for _, hd := range h {
-		if strings.EqualFold(hd.Key, "event-type") && strings.EqualFold(string(hd.Value), "sms") {
-			return true
+// failedIdx — индексы [0, n), отсутствующие в okIdx.
+func failedIdx(n int, okIdx []int) []int {
+	ok := make(map[int]struct{}, len(okIdx))
+	for _, i := range okIdx {
+		ok[i] = struct{}{}
+	}
+	var out []int
+	for i := 0; i < n; i++ {
+		if _, success := ok[i]; !success {
+			out = append(out, i)
 		}
 	}
-	// по умолчанию считаем sms-событием
-	return true
+	return out
+}
+
+func isSMSMessage(h []kafka.Header) bool {
+	et, ok := headerValue(h, HeaderEventType)
+	if !ok {
+		// без заголовка считаем sms-событием
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(et), defaultEventType)
 }
@@ -0,0 +1,283 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// fakeReader отдаёт msgs по очереди, потом — io.EOF.
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	committed []kafka.Message
+}
+
+func (r *fakeReader) FetchMessage(context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.msgs) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	m := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return m, nil
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// lastCommit — последний закоммиченный оффсет партиции; -1 — не было.
+func (r *fakeReader) lastCommit(partition int) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	off := int64(-1)
+	for _, m := range r.committed {
+		if m.Partition == partition && m.Offset > off {
+			off = m.Offset
+		}
+	}
+	return off
+}
+
+// fakeWriter запоминает записанное; пока err != nil — отклоняет запись.
+type fakeWriter struct {
+	mu   sync.Mutex
+	err  error
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func (w *fakeWriter) setErr(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+func (w *fakeWriter) written() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.msgs...)
+}
+
+func newTestConsumer(t *testing.T, r *fakeReader, w *fakeWriter, dlq string) *Consumer {
+	t.Helper()
+	kr := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "sms"})
+	t.Cleanup(func() { _ = kr.Close() })
+
+	c := &Consumer{
+		r:           r,
+		tp:          "sms",
+		maxAttempts: 1,
+		backoff:     time.Millisecond,
+		dlqTopic:    dlq,
+		offsets:     newOffsetTracker(),
+		metrics:     newConsumerMetrics(noop.NewMeterProvider(), kr),
+	}
+	c.Tune(10, 1)
+	if w != nil {
+		c.rp = &republisher{w: w}
+	}
+	return c
+}
+
+func smsMsg(off int64, body string) kafka.Message {
+	return kafka.Message{Topic: "sms", Partition: 0, Offset: off, Value: []byte(body)}
+}
+
+const goodSMS = `{"phone":"+77011234567","text":"hi"}`
+
+func TestPollBatch_DecodeFailureDeadLettered(t *testing.T) {
+	r := &fakeReader{msgs: []kafka.Message{smsMsg(0, "{broken"), smsMsg(1, goodSMS)}}
+	w := &fakeWriter{}
+	c := newTestConsumer(t, r, w, "sms.dlq")
+
+	items, err := c.PollBatch(context.Background(), 0)
+	if err != nil || len(items) != 1 || items[0].commit.Offset != 1 {
+		t.Fatalf("PollBatch: %d items, %v", len(items), err)
+	}
+
+	out := w.written()
+	if len(out) != 1 || out[0].Topic != "sms.dlq" {
+		t.Fatalf("dlq writes: %+v", out)
+	}
+	if v, _ := headerValue(out[0].Headers, HeaderOriginalOffset); v != "0" {
+		t.Fatalf("x-original-offset = %q", v)
+	}
+	if _, ok := headerValue(out[0].Headers, HeaderDLQReason); !ok {
+		t.Fatal("x-dlq-reason missing")
+	}
+	if got := r.lastCommit(0); got != 0 {
+		t.Fatalf("committed offset %d, want 0", got)
+	}
+}
+
+func TestPollBatch_DecodeFailureHeldUntilDLQAccepts(t *testing.T) {
+	r := &fakeReader{msgs: []kafka.Message{smsMsg(0, "{broken"), smsMsg(1, goodSMS)}}
+	w := &fakeWriter{err: errors.New("broker down")}
+	c := newTestConsumer(t, r, w, "sms.dlq")
+	ctx := context.Background()
+
+	items, _ := c.PollBatch(ctx, 0)
+	if err := c.CommitContiguous(ctx, items, []int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.lastCommit(0); got != -1 {
+		t.Fatalf("committed past an undelivered poison message: offset %d", got)
+	}
+
+	c.retryHeld(ctx) // DLQ всё ещё недоступен
+	if got := r.lastCommit(0); got != -1 {
+		t.Fatalf("committed while DLQ is down: offset %d", got)
+	}
+
+	w.setErr(nil)
+	c.retryHeld(ctx)
+	if len(w.written()) != 1 {
+		t.Fatalf("dlq writes: %d", len(w.written()))
+	}
+	if got := r.lastCommit(0); got != 1 {
+		t.Fatalf("committed offset %d, want 1", got)
+	}
+}
+
+func TestPollBatch_DecodeFailureWithoutDLQNotCommitted(t *testing.T) {
+	r := &fakeReader{msgs: []kafka.Message{smsMsg(0, "{broken"), smsMsg(1, goodSMS)}}
+	c := newTestConsumer(t, r, nil, "")
+	ctx := context.Background()
+
+	items, _ := c.PollBatch(ctx, 0)
+	if err := c.CommitContiguous(ctx, items, []int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.lastCommit(0); got != -1 {
+		t.Fatalf("poison message skipped without DLQ: committed offset %d", got)
+	}
+	if len(c.held) != 0 {
+		t.Fatal("nothing to retry without DLQ")
+	}
+}
+
+func TestSettle_DeadLettersAfterMaxAttempts(t *testing.T) {
+	w := &fakeWriter{}
+	c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+	c.maxAttempts = 3
+
+	calls := 0
+	handler := func(_ context.Context, items []BatchItem) ([]int, error) {
+		calls++
+		return nil, errors.New("sender unavailable")
+	}
+	items := []BatchItem{{commit: smsMsg(5, goodSMS)}}
+
+	okIdx, _ := c.settle(context.Background(), handler, items, nil, errors.New("sender unavailable"))
+	if calls != 2 {
+		t.Fatalf("handler retried %d times, want 2", calls)
+	}
+	if len(okIdx) != 1 || okIdx[0] != 0 {
+		t.Fatalf("okIdx = %v, want [0]", okIdx)
+	}
+	out := w.written()
+	if len(out) != 1 || out[0].Topic != "sms.dlq" {
+		t.Fatalf("dlq writes: %+v", out)
+	}
+}
+
+func TestSettle_FailedDeadLetterIsNotAcked(t *testing.T) {
+	w := &fakeWriter{err: errors.New("broker down")}
+	c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+
+	handler := func(context.Context, []BatchItem) ([]int, error) { return nil, nil }
+	items := []BatchItem{{commit: smsMsg(5, goodSMS)}}
+
+	okIdx, stuck := c.settle(context.Background(), handler, items, nil, nil)
+	if len(okIdx) != 0 {
+		t.Fatalf("okIdx = %v, want none", okIdx)
+	}
+	if len(stuck) != 1 || stuck[0].m.Offset != 5 {
+		t.Fatalf("stuck = %+v", stuck)
+	}
+}
+
+func TestRepublisher_KeepsFirstOriginalCoordinates(t *testing.T) {
+	w := &fakeWriter{}
+	rp := &republisher{w: w}
+	src := kafka.Message{
+		Topic: "sms.retry.30s", Partition: 2, Offset: 7, Key: []byte("k"), Value: []byte("v"),
+		Headers: []kafka.Header{
+			{Key: HeaderOriginalTopic, Value: []byte("sms")},
+			{Key: HeaderOriginalOffset, Value: []byte("42")},
+			{Key: HeaderDLQReason, Value: []byte("old")},
+		},
+	}
+	if err := rp.publish(context.Background(), "sms.dlq", src, kafka.Header{Key: HeaderDLQReason, Value: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := w.written()[0]
+	if out.Topic != "sms.dlq" || string(out.Key) != "k" || string(out.Value) != "v" {
+		t.Fatalf("message: %+v", out)
+	}
+	if v, _ := headerValue(out.Headers, HeaderOriginalTopic); v != "sms" {
+		t.Fatalf("x-original-topic = %q", v)
+	}
+	if v, _ := headerValue(out.Headers, HeaderOriginalOffset); v != "42" {
+		t.Fatalf("x-original-offset = %q", v)
+	}
+	if v, _ := headerValue(out.Headers, HeaderDLQReason); v != "new" {
+		t.Fatalf("x-dlq-reason = %q", v)
+	}
+}
+
+func TestCommitAcked_NeverCommitsBackwards(t *testing.T) {
+	r := &fakeReader{}
+	c := newTestConsumer(t, r, nil, "")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for off := int64(w); off < 400; off += 8 {
+				m := smsMsg(off, goodSMS)
+				c.offsets.ack(m)
+				_ = c.commitAcked(ctx)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := int64(-1)
+	for _, m := range r.committed {
+		if m.Offset < last {
+			t.Fatalf("committed offset %d after %d", m.Offset, last)
+		}
+		last = m.Offset
+	}
+}
@@ -0,0 +1,109 @@
+package kafkaio
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/cache"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderMessageID — id, который продюсер присваивает сообщению.
+const HeaderMessageID = "message-id"
+
+const dedupePrefix = "kafka:dedupe:"
+
+// DedupeStore — хранилище ключей идемпотентности (подмножество cache.Store).
+// На батч — один MGet и один MSet.
+type DedupeStore interface {
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
+	MSet(ctx context.Context, values map[string]string, ttl time.Duration) error
+}
+
+var _ DedupeStore = (cache.Store)(nil)
+
+// ConsumerOption — опциональная настройка Consumer.
+type ConsumerOption func(*Consumer)
+
+// WithDedupe включает идемпотентное потребление: элементы, чей ключ уже
+// есть в store, считаются успешными без вызова handler'а; ключи успешно
+// обработанных записываются с TTL из KAFKA_CONSUMER_DEDUPE_TTL.
+func WithDedupe(store DedupeStore) ConsumerOption {
+	return func(c *Consumer) { c.dedupe = store }
+}
+
+// dedupeKey — стабильный ключ идемпотентности: message-id от продюсера,
+// иначе координаты исходного сообщения (переживают retry-тиры).
+func dedupeKey(m kafka.Message) string {
+	if id, ok := headerValue(m.Headers, HeaderMessageID); ok && strings.TrimSpace(id) != "" {
+		return dedupePrefix + "id:" + strings.TrimSpace(id)
+	}
+
+	topic, part, off := m.Topic, strconv.Itoa(m.Partition), strconv.FormatInt(m.Offset, 10)
+	if v, ok := headerValue(m.Headers, HeaderOriginalTopic); ok {
+		topic = v
+		part, _ = headerValue(m.Headers, HeaderOriginalPartition)
+		off, _ = headerValue(m.Headers, HeaderOriginalOffset)
+	}
+	return dedupePrefix + topic + ":" + part + ":" + off
+}
+
+// withDedupe оборачивает handler: уже виденные элементы сразу попадают в
+// okIdx, остальные уходят в handler, а их успехи записываются в store.
+// Ошибки store не блокируют обработку (fail-open): лучше дубль, чем потеря.
+func (c *Consumer) withDedupe(handler Handler) Handler {
+	if c.dedupe == nil {
+		return handler
+	}
+	return func(ctx context.Context, items []BatchItem) ([]int, error) {
+		keys := make([]string, len(items))
+		for i, it := range items {
+			keys[i] = dedupeKey(it.commit)
+		}
+		seen, err := c.dedupe.MGet(ctx, keys...)
+		if err != nil {
+			log.Warn().Err(err).Msg("dedupe lookup failed")
+			seen = nil
+		}
+
+		var (
+			okIdx []int
+			fresh []BatchItem
+			orig  []int // индекс в fresh → индекс в items
+		)
+		for i, it := range items {
+			if _, dup := seen[keys[i]]; dup {
+				okIdx = append(okIdx, i)
+				continue
+			}
+			fresh = append(fresh, it)
+			orig = append(orig, i)
+		}
+		if len(okIdx) > 0 {
+			log.Debug().Int("duplicates", len(okIdx)).Msg("skipping already processed messages")
+		}
+		if len(fresh) == 0 {
+			return okIdx, nil
+		}
+
+		freshOk, err := handler(ctx, fresh)
+		done := make(map[string]string, len(freshOk))
+		for _, i := range freshOk {
+			if i < 0 || i >= len(fresh) {
+				continue
+			}
+			done[keys[orig[i]]] = "1"
+			okIdx = append(okIdx, orig[i])
+		}
+		if len(done) > 0 {
+			if serr := c.dedupe.MSet(ctx, done, c.dedupeTTL); serr != nil {
+				log.Warn().Err(serr).Msg("dedupe record failed")
+			}
+		}
+		return okIdx, err
+	}
+}
@@ -0,0 +1,117 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pay_flow_go/internal/cache"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDedupeKey(t *testing.T) {
+	withID := kafka.Message{Topic: "sms", Partition: 1, Offset: 5,
+		Headers: []kafka.Header{{Key: HeaderMessageID, Value: []byte(" abc ")}}}
+	if got := dedupeKey(withID); got != dedupePrefix+"id:abc" {
+		t.Fatalf("with message-id: %q", got)
+	}
+
+	plain := kafka.Message{Topic: "sms", Partition: 1, Offset: 5}
+	if got := dedupeKey(plain); got != dedupePrefix+"sms:1:5" {
+		t.Fatalf("without message-id: %q", got)
+	}
+
+	// после retry-тира ключ тот же, что и у исходного сообщения
+	retried := kafka.Message{Topic: "sms.retry.30s", Partition: 0, Offset: 99, Headers: []kafka.Header{
+		{Key: HeaderOriginalTopic, Value: []byte("sms")},
+		{Key: HeaderOriginalPartition, Value: []byte("1")},
+		{Key: HeaderOriginalOffset, Value: []byte("5")},
+	}}
+	if got := dedupeKey(retried); got != dedupeKey(plain) {
+		t.Fatalf("retried: %q, want %q", got, dedupeKey(plain))
+	}
+}
+
+// countingStore считает обращения к store.
+type countingStore struct {
+	DedupeStore
+	mgets, msets int
+	err          error
+}
+
+func (s *countingStore) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	s.mgets++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.DedupeStore.MGet(ctx, keys...)
+}
+
+func (s *countingStore) MSet(ctx context.Context, values map[string]string, ttl time.Duration) error {
+	s.msets++
+	return s.DedupeStore.MSet(ctx, values, ttl)
+}
+
+func dedupeItems(offs ...int64) []BatchItem {
+	out := make([]BatchItem, len(offs))
+	for i, off := range offs {
+		out[i] = BatchItem{commit: smsMsg(off, goodSMS)}
+	}
+	return out
+}
+
+func TestWithDedupe_SkipsSeenAndRecordsSuccesses(t *testing.T) {
+	mem := cache.NewMemory(0, cache.WithJanitorInterval(0))
+	defer mem.Close()
+	store := &countingStore{DedupeStore: mem}
+	c := newTestConsumer(t, &fakeReader{}, nil, "")
+	c.dedupe, c.dedupeTTL = store, time.Hour
+
+	var calls [][]int64
+	h := c.withDedupe(func(_ context.Context, items []BatchItem) ([]int, error) {
+		var offs []int64
+		for _, it := range items {
+			offs = append(offs, it.commit.Offset)
+		}
+		calls = append(calls, offs)
+		return []int{0}, errors.New("second failed") // успешен только первый
+	})
+
+	ctx := context.Background()
+	okIdx, err := h(ctx, dedupeItems(1, 2))
+	if err == nil || len(okIdx) != 1 || okIdx[0] != 0 {
+		t.Fatalf("first batch: okIdx %v, err %v", okIdx, err)
+	}
+	if store.mgets != 1 || store.msets != 1 {
+		t.Fatalf("round-trips per batch: %d MGet, %d MSet", store.mgets, store.msets)
+	}
+
+	// повтор после ребаланса: 1 уже обработан, 2 — нет
+	okIdx, _ = h(ctx, dedupeItems(1, 2))
+	if len(calls) != 2 || len(calls[1]) != 1 || calls[1][0] != 2 {
+		t.Fatalf("handler calls: %v", calls)
+	}
+	if len(okIdx) != 2 {
+		t.Fatalf("second batch okIdx: %v", okIdx)
+	}
+}
+
+func TestWithDedupe_StoreErrorFailsOpen(t *testing.T) {
+	mem := cache.NewMemory(0, cache.WithJanitorInterval(0))
+	defer mem.Close()
+	store := &countingStore{DedupeStore: mem, err: errors.New("redis down")}
+	c := newTestConsumer(t, &fakeReader{}, nil, "")
+	c.dedupe, c.dedupeTTL = store, time.Hour
+
+	handled := 0
+	h := c.withDedupe(func(_ context.Context, items []BatchItem) ([]int, error) {
+		handled += len(items)
+		return []int{0, 1}, nil
+	})
+	okIdx, err := h(context.Background(), dedupeItems(1, 2))
+	if err != nil || handled != 2 || len(okIdx) != 2 {
+		t.Fatalf("handled %d, okIdx %v, err %v", handled, okIdx, err)
+	}
+}
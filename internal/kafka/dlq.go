@@ -0,0 +1,98 @@
+package kafkaio
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которыми помечаются сообщения в служебных топиках.
+const (
+	HeaderDLQReason         = "x-dlq-reason"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderOriginalTime      = "x-original-time"
+)
+
+// messageWriter — запись в Kafka: *kafka.Writer или подмена в тестах.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// republisher — пишет копии входящих сообщений в служебные топики
+// (DLQ и т.п.), сохраняя ключ, заголовки и координаты оригинала.
+type republisher struct {
+	w messageWriter
+}
+
+func newRepublisher(cfg *config.Kafka, d *kafka.Dialer) *republisher {
+	return &republisher{w: &kafka.Writer{
+		Addr:                   kafka.TCP(splitCSV(cfg.Client.BootstrapServers)...),
+		Balancer:               &kafka.Hash{}, // тот же ключ — та же партиция
+		RequiredAcks:           kafka.RequireAll,
+		BatchTimeout:           10 * time.Millisecond,
+		MaxAttempts:            maxInt(cfg.Producer.Retries, 3),
+		AllowAutoTopicCreation: cfg.Producer.AllowAutoTopicCreation,
+		Transport: &kafka.Transport{
+			TLS:  d.TLS,
+			SASL: d.SASLMechanism,
+		},
+	}}
+}
+
+// publish кладёт src в topic. Заголовки extra перезаписывают одноимённые
+// заголовки оригинала. x-original-* проставляются только при первой
+// переотправке, чтобы после retry-тиров в DLQ были координаты исходника.
+func (r *republisher) publish(ctx context.Context, topic string, src kafka.Message, extra ...kafka.Header) error {
+	var meta []kafka.Header
+	if !hasHeader(src.Headers, HeaderOriginalTopic) {
+		meta = []kafka.Header{
+			{Key: HeaderOriginalTopic, Value: []byte(src.Topic)},
+			{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(src.Partition))},
+			{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(src.Offset, 10))},
+			{Key: HeaderOriginalTime, Value: []byte(src.Time.UTC().Format(time.RFC3339Nano))},
+		}
+	}
+	meta = append(meta, extra...)
+
+	return r.w.WriteMessages(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     src.Key,
+		Value:   src.Value,
+		Headers: mergeHeaders(src.Headers, meta),
+	})
+}
+
+func (r *republisher) close() error { return r.w.Close() }
+
+// mergeHeaders возвращает base без ключей из override плюс override.
+func mergeHeaders(base, override []kafka.Header) []kafka.Header {
+	out := make([]kafka.Header, 0, len(base)+len(override))
+	for _, h := range base {
+		if !hasHeader(override, h.Key) {
+			out = append(out, h)
+		}
+	}
+	return append(out, override...)
+}
+
+func hasHeader(h []kafka.Header, key string) bool {
+	_, ok := headerValue(h, key)
+	return ok
+}
+
+func headerValue(h []kafka.Header, key string) (string, bool) {
+	for _, hd := range h {
+		if strings.EqualFold(hd.Key, key) {
+			return string(hd.Value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,182 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Delivery — результат асинхронной отправки (future).
+type Delivery struct {
+	done     chan struct{}
+	err      error
+	cb       func(error)
+	enqueued time.Time
+	span     trace.Span
+}
+
+// Done закрывается, когда брокер подтвердил (или отверг) сообщение.
+func (d *Delivery) Done() <-chan struct{} { return d.done }
+
+// Err — итог доставки; имеет смысл после Done.
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Wait ждёт доставку или отмену ctx.
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Delivery) finish(err error) {
+	d.err = err
+	endSpan(d.span, err)
+	close(d.done)
+	if d.cb != nil {
+		d.cb(err)
+	}
+}
+
+// asyncQueue — асинхронный writer (Async + Completion) и ограничение
+// на число сообщений в полёте.
+type asyncQueue struct {
+	w     messageWriter
+	slots chan struct{}
+
+	deliveries metric.Int64Counter
+	latency    metric.Float64Histogram
+}
+
+func newAsyncQueue(p *Producer, cfg *config.Kafka, d *kafka.Dialer) *asyncQueue {
+	q := &asyncQueue{slots: make(chan struct{}, maxInt(cfg.Producer.QueueSize, 1))}
+
+	w := newWriter(cfg, d)
+	w.Async = true
+	w.Completion = func(msgs []kafka.Message, err error) { p.complete(msgs, err) }
+	q.w = w
+
+	m := otel.Meter(tracerName)
+	q.deliveries, _ = m.Int64Counter("kafka.producer.deliveries",
+		metric.WithDescription("Async deliveries by result"))
+	q.latency, _ = m.Float64Histogram("kafka.producer.delivery.duration",
+		metric.WithDescription("Time from Enqueue to broker ack"), metric.WithUnit("ms"))
+	_, _ = m.Int64ObservableGauge("kafka.producer.queue.depth",
+		metric.WithDescription("Messages enqueued and not yet acked"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(len(q.slots)))
+			return nil
+		}))
+	return q
+}
+
+// Enqueue — неблокирующая отправка sms. Возвращает Delivery, по которому
+// можно дождаться подтверждения; cb (может быть nil) вызывается по
+// завершении не из горутины вызывающего. Если Enqueue вернул ошибку,
+// сообщение не принято: ни Delivery, ни cb не будет. Если в полёте уже
+// QueueSize сообщений, Enqueue ждёт освобождения места или отмены ctx
+// (backpressure). Пока спул не пуст, сообщение сразу дописывается в него,
+// чтобы не обогнать уже отложенные.
+func (p *Producer) Enqueue(ctx context.Context, sms SMS, cb func(error)) (*Delivery, error) {
+	msg, err := smsMessage(p.source, p.smsSchema, sms)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Delivery{done: make(chan struct{}), cb: cb, enqueued: time.Now()}
+	// span не привязан к ctx вызова: он закончится в Completion, после ответа клиенту
+	_, d.span = startPublishSpan(context.WithoutCancel(ctx), p.w.Topic, &msg)
+
+	if p.spool != nil && !p.spool.empty() {
+		if err := p.spool.append(msg); err != nil {
+			endSpan(d.span, err)
+			return nil, err
+		}
+		go p.aq.report(d, nil)
+		return d, nil
+	}
+
+	select {
+	case p.aq.slots <- struct{}{}:
+	case <-ctx.Done():
+		endSpan(d.span, ctx.Err())
+		return nil, ctx.Err()
+	}
+
+	msg.WriterData = d
+	if err := p.aq.w.WriteMessages(ctx, msg); err != nil {
+		<-p.aq.slots
+		// async writer синхронно запрашивает метаданные — при недоступном
+		// брокере ошибка приходит сюда, а не в Completion; как и write, спулим
+		if p.spool != nil {
+			msg.WriterData = nil
+			serr := p.spool.append(msg)
+			if serr == nil {
+				log.Warn().Err(err).Msg("async kafka write failed; spooled to disk")
+				go p.aq.report(d, nil)
+				return d, nil
+			}
+			err = errors.Join(err, serr)
+		}
+		endSpan(d.span, err)
+		return nil, err
+	}
+	return d, nil
+}
+
+// complete — Completion асинхронного writer'а. Неудачные сообщения при
+// включённом спуле уходят на диск и считаются доставленными.
+func (p *Producer) complete(msgs []kafka.Message, err error) {
+	if err != nil && p.spool != nil {
+		if serr := p.spool.append(msgs...); serr != nil {
+			err = errors.Join(err, serr)
+		} else {
+			log.Warn().Err(err).Int("messages", len(msgs)).Msg("async kafka write failed; spooled to disk")
+			err = nil
+		}
+	}
+
+	if err != nil {
+		log.Error().Err(err).Int("messages", len(msgs)).Msg("async kafka delivery failed")
+	}
+
+	for _, m := range msgs {
+		d, ok := m.WriterData.(*Delivery)
+		if !ok {
+			continue
+		}
+		<-p.aq.slots
+		p.aq.report(d, err)
+	}
+}
+
+// report пишет метрики доставки и завершает d.
+func (q *asyncQueue) report(d *Delivery, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	attrs := metric.WithAttributes(attribute.String("result", result))
+	ctx := context.Background()
+	q.deliveries.Add(ctx, 1, attrs)
+	q.latency.Record(ctx, float64(time.Since(d.enqueued))/float64(time.Millisecond), attrs)
+	d.finish(err)
+}
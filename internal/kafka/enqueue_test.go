@@ -0,0 +1,141 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func newTestProducer(t *testing.T, w *fakeWriter, sp *spool) *Producer {
+	t.Helper()
+	m := noop.NewMeterProvider().Meter(tracerName)
+	q := &asyncQueue{w: w, slots: make(chan struct{}, 1)}
+	q.deliveries, _ = m.Int64Counter("deliveries")
+	q.latency, _ = m.Float64Histogram("latency")
+	return &Producer{w: &kafka.Writer{Topic: "sms"}, smsSchema: "1", source: "test", aq: q, spool: sp}
+}
+
+func testSMS(text string) SMS {
+	return SMS{UserID: uuid.New(), Phone: "+77011234567", Text: text}
+}
+
+func TestEnqueue_WriteErrorReturnedOnce(t *testing.T) {
+	w := &fakeWriter{err: errors.New("writer closed")}
+	p := newTestProducer(t, w, nil)
+
+	var calls atomic.Int32
+	d, err := p.Enqueue(context.Background(), testSMS("a"), func(error) { calls.Add(1) })
+	if err == nil || d != nil {
+		t.Fatalf("Enqueue = %v, %v; want error and no Delivery", d, err)
+	}
+	if calls.Load() != 0 {
+		t.Fatal("callback must not be called when Enqueue returns an error")
+	}
+	if len(p.aq.slots) != 0 {
+		t.Fatal("slot not released")
+	}
+}
+
+func TestEnqueue_CompletionReportsViaDelivery(t *testing.T) {
+	w := &fakeWriter{}
+	p := newTestProducer(t, w, nil)
+
+	got := make(chan error, 1)
+	d, err := p.Enqueue(context.Background(), testSMS("a"), func(err error) { got <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// брокер отверг сообщение; спула нет — ошибка уходит в Delivery и cb
+	p.complete(w.written(), errors.New("not leader"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Wait(ctx); err == nil {
+		t.Fatal("Delivery must carry the broker error")
+	}
+	if err := <-got; err == nil {
+		t.Fatal("callback must get the broker error")
+	}
+	if len(p.aq.slots) != 0 {
+		t.Fatal("slot not released")
+	}
+}
+
+func TestEnqueue_SpoolFirstKeepsOrder(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.close()
+	if err := sp.append(kafka.Message{Value: []byte("spooled")}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &fakeWriter{}
+	p := newTestProducer(t, w, sp)
+	done := make(chan error, 1)
+	d, err := p.Enqueue(context.Background(), testSMS("after"), func(err error) { done <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(w.written()) != 0 {
+		t.Fatal("message overtook the spool")
+	}
+
+	var order []string
+	if err := sp.replay(context.Background(), func(_ context.Context, msgs ...kafka.Message) error {
+		for _, m := range msgs {
+			if v, _ := headerValue(m.Headers, HeaderEventType); v != "" {
+				order = append(order, v)
+				continue
+			}
+			order = append(order, string(m.Value))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "spooled" || order[1] != defaultEventType {
+		t.Fatalf("replay order: %v", order)
+	}
+}
+
+func TestEnqueue_WriteErrorSpooledWhenSpoolEnabled(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.close()
+
+	w := &fakeWriter{err: errors.New("no leader for partition")}
+	p := newTestProducer(t, w, sp)
+	done := make(chan error, 1)
+	d, err := p.Enqueue(context.Background(), testSMS("a"), func(err error) { done <- err })
+	if err != nil {
+		t.Fatalf("Enqueue must spool instead of failing: %v", err)
+	}
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if sp.empty() {
+		t.Fatal("message not spooled")
+	}
+	if len(p.aq.slots) != 0 {
+		t.Fatal("slot not released")
+	}
+}
@@ -0,0 +1,131 @@
+package kafkaio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// CloudEvents Kafka binding, binary mode: атрибуты — в заголовках ce_*,
+// data — в теле сообщения как есть.
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	HeaderContentType    = "content-type"
+	HeaderCESpecVersion  = "ce_specversion"
+	HeaderCEID           = "ce_id"
+	HeaderCESource       = "ce_source"
+	HeaderCEType         = "ce_type"
+	HeaderCESubject      = "ce_subject"
+	HeaderCETime         = "ce_time"
+	HeaderCEPartitionKey = "ce_partitionkey"
+)
+
+// ErrNoEventType — у события не задан Type: без event-type консьюмер
+// принял бы его за sms.
+var ErrNoEventType = errors.New("envelope: event type is required")
+
+// Envelope — типизированное событие в формате CloudEvents.
+type Envelope[T any] struct {
+	ID              string    // по умолчанию — новый UUID
+	Source          string    // по умолчанию — KAFKA_PRODUCER_EVENT_SOURCE
+	Type            string    // event-type, по нему маршрутизирует Router
+	Subject         string    // необязательный
+	Time            time.Time // по умолчанию — now
+	DataContentType string    // по умолчанию — application/json
+	SpecVersion     string    // по умолчанию — 1.0
+	SchemaVer       string    // версия схемы data (schema-ver), по умолчанию — 1
+	Key             string    // ключ партиционирования (ce_partitionkey)
+	Data            T
+}
+
+// NewEnvelope — конверт с заполненными id/time/specversion.
+func NewEnvelope[T any](eventType, key string, data T) Envelope[T] {
+	return Envelope[T]{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+		SpecVersion: CloudEventsSpecVersion,
+		Key:         key,
+		Data:        data,
+	}
+}
+
+// Publish сериализует e.Data в JSON и отправляет событие через p
+// с общими для всех событий заголовками, ключом и трейсингом.
+func Publish[T any](ctx context.Context, p *Producer, e Envelope[T]) error {
+	msg, err := e.message(p.source, func(v T) ([]byte, error) { return json.Marshal(v) })
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, msg)
+}
+
+// message собирает kafka.Message; enc сериализует data.
+func (e Envelope[T]) message(source string, enc func(T) ([]byte, error)) (kafka.Message, error) {
+	if strings.TrimSpace(e.Type) == "" {
+		return kafka.Message{}, ErrNoEventType
+	}
+	payload, err := enc(e.Data)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Source == "" {
+		e.Source = source
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.DataContentType == "" {
+		e.DataContentType = "application/json"
+	}
+	if e.SpecVersion == "" {
+		e.SpecVersion = CloudEventsSpecVersion
+	}
+	if e.SchemaVer == "" {
+		e.SchemaVer = defaultSchemaVer
+	}
+
+	h := []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(e.DataContentType)},
+		{Key: HeaderCESpecVersion, Value: []byte(e.SpecVersion)},
+		{Key: HeaderCEID, Value: []byte(e.ID)},
+		{Key: HeaderCESource, Value: []byte(e.Source)},
+		{Key: HeaderCEType, Value: []byte(e.Type)},
+		{Key: HeaderCETime, Value: []byte(e.Time.UTC().Format(time.RFC3339Nano))},
+		// исторические заголовки — их читают Router и дедупликация
+		{Key: HeaderEventType, Value: []byte(e.Type)},
+		{Key: HeaderSchemaVer, Value: []byte(e.SchemaVer)},
+		{Key: HeaderMessageID, Value: []byte(e.ID)},
+	}
+	if e.Subject != "" {
+		h = append(h, kafka.Header{Key: HeaderCESubject, Value: []byte(e.Subject)})
+	}
+	if e.Key != "" {
+		h = append(h, kafka.Header{Key: HeaderCEPartitionKey, Value: []byte(e.Key)})
+	}
+
+	m := kafka.Message{Value: payload, Time: e.Time, Headers: h}
+	if e.Key != "" {
+		m.Key = []byte(e.Key)
+	}
+	return m, nil
+}
+
+func eventSource(cfg *config.Kafka) string {
+	if s := strings.TrimSpace(cfg.Producer.EventSource); s != "" {
+		return s
+	}
+	return "pay_flow_go"
+}
@@ -0,0 +1,66 @@
+package kafkaio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type otp struct {
+	Code string `json:"code"`
+}
+
+func TestEnvelope_BinaryModeHeaders(t *testing.T) {
+	e := NewEnvelope("otp", "user-1", otp{Code: "1234"})
+	e.Subject = "login"
+
+	m, err := e.message("pay_flow_go", func(v otp) ([]byte, error) { return json.Marshal(v) })
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+	if string(m.Key) != "user-1" || string(m.Value) != `{"code":"1234"}` {
+		t.Fatalf("key/value: %q %q", m.Key, m.Value)
+	}
+
+	want := map[string]string{
+		HeaderCESpecVersion:  CloudEventsSpecVersion,
+		HeaderCEID:           e.ID,
+		HeaderCESource:       "pay_flow_go",
+		HeaderCEType:         "otp",
+		HeaderCESubject:      "login",
+		HeaderCEPartitionKey: "user-1",
+		HeaderContentType:    "application/json",
+		HeaderEventType:      "otp",
+		HeaderSchemaVer:      "1",
+		HeaderMessageID:      e.ID,
+	}
+	for k, v := range want {
+		if got, _ := headerValue(m.Headers, k); got != v {
+			t.Errorf("header %s: want %q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestSMSMessage_UsesSchemaVersion(t *testing.T) {
+	m, err := smsMessage("src", "2", sampleSMS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := headerValue(m.Headers, HeaderSchemaVer); v != "2" {
+		t.Fatalf("schema-ver: %q", v)
+	}
+	if string(m.Key) != sampleSMS().UserID.String() {
+		t.Fatalf("key: %q", m.Key)
+	}
+	if _, err := smsMessage("src", "9", sampleSMS()); err == nil {
+		t.Fatal("expected unsupported schema error")
+	}
+}
+
+func TestPublish_RejectsEmptyType(t *testing.T) {
+	e := NewEnvelope("", "user-1", otp{Code: "1234"})
+	if err := Publish(context.Background(), &Producer{}, e); !errors.Is(err, ErrNoEventType) {
+		t.Fatalf("want ErrNoEventType, got %v", err)
+	}
+}
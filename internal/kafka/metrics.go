@@ -0,0 +1,109 @@
+package kafkaio
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Причины пропуска сообщения без вызова handler'а.
+const (
+	skipRedelivered = "redelivered"   // уже подтверждено (повторная выборка)
+	skipFiltered    = "filtered"      // не sms без Router'а
+	skipUnknown     = "unknown_event" // нет маршрута в Router'е
+)
+
+// consumerMetrics — OTel-метрики консьюмера. Consumer берёт инструменты
+// из глобального MeterProvider: без SDK они no-op.
+type consumerMetrics struct {
+	batchSize      metric.Int64Histogram
+	handlerLatency metric.Float64Histogram
+	commitFailures metric.Int64Counter
+	decodeFailures metric.Int64Counter
+	skipped        metric.Int64Counter
+
+	mu  sync.Mutex
+	lag map[lagKey]int64 // по последнему выбранному сообщению партиции
+}
+
+type lagKey struct {
+	topic     string
+	partition int
+}
+
+func newConsumerMetrics(mp metric.MeterProvider, r *kafka.Reader) *consumerMetrics {
+	cm := &consumerMetrics{lag: map[lagKey]int64{}}
+
+	m := mp.Meter(tracerName)
+	cm.batchSize, _ = m.Int64Histogram("kafka.consumer.batch.size",
+		metric.WithDescription("Messages per batch passed to the handler"))
+	cm.handlerLatency, _ = m.Float64Histogram("kafka.consumer.handler.duration",
+		metric.WithDescription("Handler call duration"), metric.WithUnit("ms"))
+	cm.commitFailures, _ = m.Int64Counter("kafka.consumer.commit.failures",
+		metric.WithDescription("Failed offset commits"))
+	cm.decodeFailures, _ = m.Int64Counter("kafka.consumer.decode.failures",
+		metric.WithDescription("Messages that could not be decoded"))
+	cm.skipped, _ = m.Int64Counter("kafka.consumer.skipped",
+		metric.WithDescription("Messages acked without calling the handler, by reason"))
+
+	_, _ = m.Int64ObservableGauge("kafka.consumer.lag",
+		metric.WithDescription("High-water mark minus next offset to read, per partition"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			cm.mu.Lock()
+			defer cm.mu.Unlock()
+			for k, v := range cm.lag {
+				o.Observe(v, metric.WithAttributes(topicAttr(k.topic), attribute.Int("partition", k.partition)))
+			}
+			return nil
+		}))
+	// Reader.Stats().Lag — оценка самого reader'а; есть и до первой выборки
+	_, _ = m.Int64ObservableGauge("kafka.consumer.reader.lag",
+		metric.WithDescription("Lag reported by the reader"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			st := r.Stats()
+			o.Observe(st.Lag, metric.WithAttributes(topicAttr(st.Topic)))
+			return nil
+		}))
+	return cm
+}
+
+// observeLag запоминает лаг партиции по high-water mark выбранного сообщения.
+func (cm *consumerMetrics) observeLag(m kafka.Message) {
+	if m.HighWaterMark <= 0 {
+		return
+	}
+	cm.mu.Lock()
+	cm.lag[lagKey{m.Topic, m.Partition}] = max(m.HighWaterMark-m.Offset-1, 0)
+	cm.mu.Unlock()
+}
+
+func (cm *consumerMetrics) skip(ctx context.Context, topic, reason string) {
+	cm.skipped.Add(ctx, 1, metric.WithAttributes(topicAttr(topic), attribute.String("reason", reason)))
+}
+
+func (cm *consumerMetrics) decodeFailed(ctx context.Context, topic string) {
+	cm.decodeFailures.Add(ctx, 1, metric.WithAttributes(topicAttr(topic)))
+}
+
+func (cm *consumerMetrics) commitFailed(ctx context.Context, topic string) {
+	cm.commitFailures.Add(ctx, 1, metric.WithAttributes(topicAttr(topic)))
+}
+
+// handled записывает размер батча и длительность вызова handler'а.
+func (cm *consumerMetrics) handled(ctx context.Context, topic string, n int, started time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	cm.batchSize.Record(ctx, int64(n), metric.WithAttributes(topicAttr(topic)))
+	cm.handlerLatency.Record(ctx, float64(time.Since(started))/float64(time.Millisecond),
+		metric.WithAttributes(topicAttr(topic), attribute.String("result", result)))
+}
+
+func topicAttr(topic string) attribute.KeyValue {
+	return attribute.String("messaging.destination.name", topic)
+}
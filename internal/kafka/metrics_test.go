@@ -0,0 +1,53 @@
+package kafkaio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConsumerMetrics_LagPerPartition(t *testing.T) {
+	rd := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(rd))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	r := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "sms"})
+	defer r.Close()
+	cm := newConsumerMetrics(mp, r)
+
+	cm.observeLag(kafka.Message{Topic: "sms", Partition: 0, Offset: 9, HighWaterMark: 10})
+	cm.observeLag(kafka.Message{Topic: "sms", Partition: 1, Offset: 4, HighWaterMark: 20})
+	cm.skip(context.Background(), "sms", skipFiltered)
+
+	var rm metricdata.ResourceMetrics
+	if err := rd.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	lag := map[int64]int64{}
+	var skipped int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case "kafka.consumer.lag":
+				for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+					p, _ := dp.Attributes.Value("partition")
+					lag[p.AsInt64()] = dp.Value
+				}
+			case "kafka.consumer.skipped":
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					skipped += dp.Value
+				}
+			}
+		}
+	}
+	if lag[0] != 0 || lag[1] != 15 {
+		t.Fatalf("lag = %v, want map[0:0 1:15]", lag)
+	}
+	if skipped != 1 {
+		t.Fatalf("skipped = %d, want 1", skipped)
+	}
+}
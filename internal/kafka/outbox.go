@@ -0,0 +1,338 @@
+package kafkaio
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// OutboxSchema — DDL таблицы outbox (PostgreSQL). Применяется миграцией
+// той же БД, в которой живёт бизнес-транзакция.
+const OutboxSchema = `
+CREATE TABLE IF NOT EXISTS kafka_outbox (
+	id            BIGSERIAL   PRIMARY KEY,
+	topic         TEXT        NOT NULL,
+	key           BYTEA,
+	value         BYTEA       NOT NULL,
+	headers       JSONB       NOT NULL DEFAULT '[]',
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+	claimed_until TIMESTAMPTZ,
+	sent_at       TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS kafka_outbox_pending_idx ON kafka_outbox (id) WHERE sent_at IS NULL;
+`
+
+// outboxLease — на сколько relay забирает строки себе. Если реплика
+// упала между публикацией и отметкой sent_at, строки через lease
+// заберёт другая (будут дубли — at-least-once).
+const outboxLease = time.Minute
+
+// Execer — то, через что пишется событие: *sql.Tx бизнес-операции
+// (или *sql.DB, если транзакции нет).
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// OutboxStats — состояние relay.
+type OutboxStats struct {
+	Pending   int64         // неотправленных записей
+	Lag       time.Duration // возраст самой старой неотправленной
+	LastRelay time.Time     // последний успешный проход relay
+}
+
+// Outbox — transactional outbox: событие пишется в таблицу в той же
+// транзакции, что и бизнес-данные, а relay публикует его в Kafka
+// (at-least-once) и отмечает sent_at. Откат транзакции — нет события,
+// недоступный брокер — событие ждёт в таблице.
+type Outbox struct {
+	store     outboxStore
+	w         messageWriter
+	topic     string // топик по умолчанию (KAFKA_PRODUCER_TOPIC)
+	smsSchema string
+	source    string
+	batch     int
+	interval  time.Duration
+	retention time.Duration
+
+	mu    sync.Mutex
+	stats OutboxStats
+}
+
+func NewOutbox(db *sql.DB, cfg *config.Kafka) *Outbox {
+	w := newWriter(cfg, newDialer(cfg))
+	w.Topic = "" // топик берётся из записи
+
+	interval := cfg.Outbox.Interval
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	return &Outbox{
+		store:     sqlOutboxStore{db: db},
+		w:         w,
+		topic:     cfg.Client.ProducerTopic,
+		smsSchema: smsSchemaVer(cfg),
+		source:    eventSource(cfg),
+		batch:     maxInt(cfg.Outbox.BatchSize, 1),
+		interval:  interval,
+		retention: cfg.Outbox.Retention,
+	}
+}
+
+type outboxHeader struct {
+	Key   string `json:"k"`
+	Value []byte `json:"v"`
+}
+
+// Add кладёт сообщение в outbox через tx. Пустой m.Topic — топик продюсера.
+// Контекст трейса сохраняется в заголовках, так что relay продолжает trace.
+func (o *Outbox) Add(ctx context.Context, tx Execer, m kafka.Message) error {
+	topic := m.Topic
+	if topic == "" {
+		topic = o.topic
+	}
+	injectTrace(ctx, &m)
+
+	hs := make([]outboxHeader, 0, len(m.Headers))
+	for _, h := range m.Headers {
+		hs = append(hs, outboxHeader{Key: h.Key, Value: h.Value})
+	}
+	hb, err := json.Marshal(hs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO kafka_outbox (topic, key, value, headers) VALUES ($1, $2, $3, $4)`,
+		topic, m.Key, m.Value, hb)
+	return err
+}
+
+// AddSMS — Add для sms (те же заголовки и схема, что у Producer.ProduceSMS).
+func (o *Outbox) AddSMS(ctx context.Context, tx Execer, sms SMS) error {
+	m, err := smsMessage(o.source, o.smsSchema, sms)
+	if err != nil {
+		return err
+	}
+	return o.Add(ctx, tx, m)
+}
+
+// Start — неблокирующий запуск relay.
+func (o *Outbox) Start(ctx context.Context) {
+	go o.run(ctx)
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// выгребаем, пока есть полные пачки
+			for {
+				n, err := o.relay(ctx)
+				if err != nil {
+					log.Error().Err(err).Msg("outbox relay failed")
+					break
+				}
+				if n < o.batch {
+					break
+				}
+			}
+			if err := o.refreshStats(ctx); err != nil {
+				log.Warn().Err(err).Msg("outbox stats failed")
+			}
+			if o.retention > 0 && time.Since(lastCleanup) > time.Hour {
+				lastCleanup = time.Now()
+				if err := o.cleanup(ctx); err != nil {
+					log.Warn().Err(err).Msg("outbox cleanup failed")
+				}
+			}
+		}
+	}
+}
+
+// relay публикует одну пачку в три шага, не держа транзакцию (и
+// блокировки строк) открытой на время записи в Kafka: короткий claim
+// с lease, публикация, отметка sent_at. Если запись в Kafka не удалась —
+// строки возвращаются в очередь и уйдут в следующий раз.
+func (o *Outbox) relay(ctx context.Context) (int, error) {
+	rows, err := o.store.claim(ctx, o.batch, outboxLease)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int64, len(rows))
+	msgs := make([]kafka.Message, len(rows))
+	for i, r := range rows {
+		ids[i], msgs[i] = r.id, r.msg
+	}
+
+	if err := o.w.WriteMessages(ctx, msgs...); err != nil {
+		// не ждём истечения lease — вернуть строки можно сразу
+		if rerr := o.store.release(context.WithoutCancel(ctx), ids); rerr != nil {
+			log.Warn().Err(rerr).Msg("outbox release failed; rows wait for the lease to expire")
+		}
+		return 0, err
+	}
+	if err := o.store.markSent(ctx, ids); err != nil {
+		// сообщения уже в Kafka — после lease уйдут ещё раз (at-least-once)
+		return 0, err
+	}
+
+	o.mu.Lock()
+	o.stats.LastRelay = time.Now()
+	o.mu.Unlock()
+	return len(msgs), nil
+}
+
+func (o *Outbox) refreshStats(ctx context.Context) error {
+	pending, oldest, err := o.store.pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats.Pending = pending
+	o.stats.Lag = 0
+	if !oldest.IsZero() {
+		o.stats.Lag = time.Since(oldest)
+	}
+	return nil
+}
+
+func (o *Outbox) cleanup(ctx context.Context) error {
+	return o.store.deleteSent(ctx, time.Now().Add(-o.retention))
+}
+
+// Stats — последнее известное состояние relay (обновляется каждый тик).
+func (o *Outbox) Stats() OutboxStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stats
+}
+
+func (o *Outbox) Close() error {
+	log.Info().Msg("closing kafka outbox relay")
+	return o.w.Close()
+}
+
+// outboxRow — забранная relay'ем запись.
+type outboxRow struct {
+	id  int64
+	msg kafka.Message
+}
+
+// outboxStore — SQL-часть outbox, которой пользуется relay.
+type outboxStore interface {
+	// claim забирает до limit неотправленных строк (по порядку id) на lease.
+	claim(ctx context.Context, limit int, lease time.Duration) ([]outboxRow, error)
+	markSent(ctx context.Context, ids []int64) error
+	// release возвращает забранные строки в очередь до истечения lease.
+	release(ctx context.Context, ids []int64) error
+	pending(ctx context.Context) (n int64, oldest time.Time, err error)
+	deleteSent(ctx context.Context, before time.Time) error
+}
+
+// sqlOutboxStore — outboxStore поверх PostgreSQL.
+type sqlOutboxStore struct {
+	db *sql.DB
+}
+
+// claim — один UPDATE, то есть своя короткая транзакция. SKIP LOCKED
+// и claimed_until не дают двум репликам забрать одни и те же строки.
+func (s sqlOutboxStore) claim(ctx context.Context, limit int, lease time.Duration) ([]outboxRow, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE kafka_outbox SET claimed_until = now() + $2 * interval '1 millisecond'
+		 WHERE id IN (
+			SELECT id FROM kafka_outbox
+			WHERE sent_at IS NULL AND (claimed_until IS NULL OR claimed_until < now())
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+		 RETURNING id, topic, key, value, headers`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	var out []outboxRow
+	for rows.Next() {
+		var (
+			r  outboxRow
+			hb []byte
+			hs []outboxHeader
+		)
+		if err := rows.Scan(&r.id, &r.msg.Topic, &r.msg.Key, &r.msg.Value, &hb); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if err := json.Unmarshal(hb, &hs); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("outbox row %d headers: %w", r.id, err)
+		}
+		for _, h := range hs {
+			r.msg.Headers = append(r.msg.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+		}
+		out = append(out, r)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, err
+	}
+	// RETURNING не гарантирует порядок
+	slices.SortFunc(out, func(a, b outboxRow) int { return cmp.Compare(a.id, b.id) })
+	return out, nil
+}
+
+func (s sqlOutboxStore) markSent(ctx context.Context, ids []int64) error {
+	q, args := inIDs(`UPDATE kafka_outbox SET sent_at = now(), claimed_until = NULL WHERE id IN `, ids)
+	_, err := s.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+func (s sqlOutboxStore) release(ctx context.Context, ids []int64) error {
+	q, args := inIDs(`UPDATE kafka_outbox SET claimed_until = NULL WHERE sent_at IS NULL AND id IN `, ids)
+	_, err := s.db.ExecContext(ctx, q, args...)
+	return err
+}
+
+func (s sqlOutboxStore) pending(ctx context.Context) (int64, time.Time, error) {
+	var (
+		n      int64
+		oldest sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT count(*), min(created_at) FROM kafka_outbox WHERE sent_at IS NULL`).Scan(&n, &oldest)
+	return n, oldest.Time, err
+}
+
+func (s sqlOutboxStore) deleteSent(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM kafka_outbox WHERE sent_at IS NOT NULL AND sent_at < $1`, before)
+	return err
+}
+
+// inIDs дописывает к prefix "($1,$2,...)" по числу ids.
+func inIDs(prefix string, ids []int64) (string, []any) {
+	ph := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		ph[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	return prefix + "(" + strings.Join(ph, ",") + ")", args
+}
@@ -0,0 +1,212 @@
+package kafkaio
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeOutboxStore — outbox в памяти с той же семантикой claim/lease.
+type fakeOutboxStore struct {
+	mu      sync.Mutex
+	now     time.Time
+	rows    map[int64]*fakeOutboxRow
+	markErr error
+}
+
+type fakeOutboxRow struct {
+	msg     kafka.Message
+	claimed time.Time
+	sent    bool
+}
+
+func newFakeOutboxStore(n int) *fakeOutboxStore {
+	s := &fakeOutboxStore{now: time.Unix(1_700_000_000, 0), rows: map[int64]*fakeOutboxRow{}}
+	for i := 1; i <= n; i++ {
+		s.rows[int64(i)] = &fakeOutboxRow{msg: kafka.Message{Topic: "sms", Value: []byte{byte('0' + i)}}}
+	}
+	return s
+}
+
+func (s *fakeOutboxStore) claim(_ context.Context, limit int, lease time.Duration) ([]outboxRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]int64, 0, len(s.rows))
+	for id := range s.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var out []outboxRow
+	for _, id := range ids {
+		r := s.rows[id]
+		if r.sent || r.claimed.After(s.now) || len(out) == limit {
+			continue
+		}
+		r.claimed = s.now.Add(lease)
+		out = append(out, outboxRow{id: id, msg: r.msg})
+	}
+	return out, nil
+}
+
+func (s *fakeOutboxStore) markSent(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markErr != nil {
+		return s.markErr
+	}
+	for _, id := range ids {
+		s.rows[id].sent, s.rows[id].claimed = true, time.Time{}
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) release(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.rows[id].claimed = time.Time{}
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) pending(context.Context) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, r := range s.rows {
+		if !r.sent {
+			n++
+		}
+	}
+	return n, time.Time{}, nil
+}
+
+func (s *fakeOutboxStore) deleteSent(context.Context, time.Time) error { return nil }
+
+func (s *fakeOutboxStore) advance(d time.Duration) {
+	s.mu.Lock()
+	s.now = s.now.Add(d)
+	s.mu.Unlock()
+}
+
+func values(msgs []kafka.Message) string {
+	var out []byte
+	for _, m := range msgs {
+		out = append(out, m.Value...)
+	}
+	return string(out)
+}
+
+func TestOutboxRelay_PublishesInOrderAndMarksSent(t *testing.T) {
+	store := newFakeOutboxStore(5)
+	w := &fakeWriter{}
+	o := &Outbox{store: store, w: w, batch: 3}
+	ctx := context.Background()
+
+	if n, err := o.relay(ctx); n != 3 || err != nil {
+		t.Fatalf("first relay: %d, %v", n, err)
+	}
+	if n, err := o.relay(ctx); n != 2 || err != nil {
+		t.Fatalf("second relay: %d, %v", n, err)
+	}
+	if n, _ := o.relay(ctx); n != 0 {
+		t.Fatalf("nothing left, relayed %d", n)
+	}
+	if got := values(w.written()); got != "12345" {
+		t.Fatalf("published %q", got)
+	}
+	if pending, _, _ := store.pending(ctx); pending != 0 {
+		t.Fatalf("pending after relay: %d", pending)
+	}
+}
+
+func TestOutboxRelay_FailedWriteReturnsRowsToQueue(t *testing.T) {
+	store := newFakeOutboxStore(2)
+	w := &fakeWriter{err: errors.New("broker down")}
+	o := &Outbox{store: store, w: w, batch: 10}
+	ctx := context.Background()
+
+	if _, err := o.relay(ctx); err == nil {
+		t.Fatal("relay must report the write error")
+	}
+	if pending, _, _ := store.pending(ctx); pending != 2 {
+		t.Fatalf("pending after failed write: %d", pending)
+	}
+
+	// строки не ждут lease: следующий проход берёт их сразу
+	w.setErr(nil)
+	if n, err := o.relay(ctx); n != 2 || err != nil {
+		t.Fatalf("retry relay: %d, %v", n, err)
+	}
+	if got := values(w.written()); got != "12" {
+		t.Fatalf("published %q", got)
+	}
+}
+
+func TestOutboxRelay_UnmarkedRowsRedeliveredAfterLease(t *testing.T) {
+	store := newFakeOutboxStore(1)
+	store.markErr = errors.New("db down")
+	w := &fakeWriter{}
+	o := &Outbox{store: store, w: w, batch: 10}
+	ctx := context.Background()
+
+	if _, err := o.relay(ctx); err == nil {
+		t.Fatal("relay must report the mark error")
+	}
+	// другая реплика до истечения lease строку не видит
+	if n, _ := o.relay(ctx); n != 0 {
+		t.Fatalf("claimed row relayed again within the lease: %d", n)
+	}
+
+	store.markErr = nil
+	store.advance(outboxLease + time.Second)
+	if n, err := o.relay(ctx); n != 1 || err != nil {
+		t.Fatalf("relay after lease: %d, %v", n, err)
+	}
+	if got := values(w.written()); got != "11" {
+		t.Fatalf("want at-least-once redelivery, published %q", got)
+	}
+}
+
+// captureExec запоминает последний запрос.
+type captureExec struct {
+	query string
+	args  []any
+}
+
+func (e *captureExec) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.query, e.args = query, args
+	return nil, nil
+}
+
+func TestOutboxAdd_WritesThroughCallersTx(t *testing.T) {
+	o := &Outbox{topic: "sms", smsSchema: "2", source: "test"}
+	tx := &captureExec{}
+
+	if err := o.AddSMS(context.Background(), tx, sampleSMS()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.args) != 4 || tx.args[0] != "sms" {
+		t.Fatalf("insert args: %v", tx.args)
+	}
+
+	var hs []outboxHeader
+	if err := json.Unmarshal(tx.args[3].([]byte), &hs); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, h := range hs {
+		got[h.Key] = string(h.Value)
+	}
+	if got[HeaderEventType] != "sms" || got[HeaderSchemaVer] != "2" || got[HeaderMessageID] == "" {
+		t.Fatalf("headers: %v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package kafkaio
+
+import (
+	"context"
+	"sync"
+)
+
+// partitionQueueDepth — сколько батчей может ждать у одного воркера.
+// Пока очередь не заполнена, медленная партиция не тормозит PollBatch.
+const partitionQueueDepth = 64
+
+// partitionPool — ограниченный пул воркеров для режима PartitionWorkers.
+// Партиция p всегда попадает к воркеру p % n, поэтому внутри партиции
+// сохраняется FIFO, а медленная партиция тормозит только свой воркер.
+type partitionPool struct {
+	queues []chan []BatchItem
+	wg     sync.WaitGroup
+}
+
+// После отмены ctx очереди вычерпываются без process: батчи не
+// подтверждены и после рестарта придут снова.
+func newPartitionPool(ctx context.Context, n int, process func([]BatchItem)) *partitionPool {
+	p := &partitionPool{queues: make([]chan []BatchItem, n)}
+	for i := range p.queues {
+		q := make(chan []BatchItem, partitionQueueDepth)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for items := range q {
+				if ctx.Err() != nil {
+					continue
+				}
+				process(items)
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch раскладывает батч по партициям (порядок внутри партиции
+// сохраняется) и ставит группы в очереди воркеров. Блокируется, только
+// когда у воркера уже partitionQueueDepth батчей, — это backpressure
+// для PollBatch: отставший воркер не копит в памяти бесконечно.
+func (p *partitionPool) dispatch(ctx context.Context, items []BatchItem) {
+	groups := map[int][]BatchItem{}
+	order := []int{}
+	for _, it := range items {
+		part := it.commit.Partition
+		if _, ok := groups[part]; !ok {
+			order = append(order, part)
+		}
+		groups[part] = append(groups[part], it)
+	}
+
+	for _, part := range order {
+		select {
+		case p.queues[part%len(p.queues)] <- groups[part]:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop закрывает очереди и ждёт, пока воркеры доработают текущие группы
+// (и, если ctx уже отменён, выбросят остальные).
+func (p *partitionPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
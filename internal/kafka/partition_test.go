@@ -0,0 +1,114 @@
+package kafkaio
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func partItems(part int, offs ...int64) []BatchItem {
+	out := make([]BatchItem, len(offs))
+	for i, off := range offs {
+		out[i] = BatchItem{commit: kafka.Message{Partition: part, Offset: off}}
+	}
+	return out
+}
+
+func TestPartitionPool_SlowPartitionDoesNotStallOthers(t *testing.T) {
+	release := make(chan struct{})
+	var (
+		mu   sync.Mutex
+		got1 []int64
+	)
+	pool := newPartitionPool(context.Background(), 2, func(items []BatchItem) {
+		if items[0].commit.Partition == 0 {
+			<-release
+			return
+		}
+		mu.Lock()
+		for _, it := range items {
+			got1 = append(got1, it.commit.Offset)
+		}
+		mu.Unlock()
+	})
+	defer pool.stop()
+	defer close(release)
+
+	// партиция 0 висит, а PollBatch продолжает отдавать батчи обеих партиций
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		for i := int64(0); i < 10; i++ {
+			pool.dispatch(context.Background(), append(partItems(0, i), partItems(1, i)...))
+		}
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatch blocked behind a slow partition")
+	}
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got1) == 10
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	for i, off := range got1 {
+		if off != int64(i) {
+			t.Fatalf("partition 1 out of order: %v", got1)
+		}
+	}
+}
+
+func TestPartitionPool_DispatchStopsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	pool := newPartitionPool(context.Background(), 1, func([]BatchItem) { <-release })
+	defer pool.stop()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := int64(0); i < partitionQueueDepth+2; i++ {
+			pool.dispatch(ctx, partItems(0, i))
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatch ignores ctx cancellation when the queue is full")
+	}
+}
+
+func TestPartitionPool_StopDropsQueuedBatchesAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	pool := newPartitionPool(ctx, 1, func([]BatchItem) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+	})
+
+	for i := int64(0); i < 10; i++ {
+		pool.dispatch(context.Background(), partItems(0, i))
+	}
+	<-started
+	cancel()
+	close(release)
+	pool.stop()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler called %d times after shutdown, want only the in-flight batch", n)
+	}
+}
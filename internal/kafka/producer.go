@@ -3,60 +3,146 @@ package kafkaio
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"pay_flow_go/internal/config"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
 )
 
-type SMS struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Phone     string    `json:"phone"`
-	IIN       string    `json:"iin"`
-	Text      string    `json:"text"`
-	Sender    string    `json:"sender,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
 type Producer struct {
-	w *kafka.Writer
+	w         *kafka.Writer
+	smsSchema string // версия схемы SMS, которую пишем
+	source    string // ce_source событий
+
+	aq         *asyncQueue // для Enqueue
+	spool      *spool      // nil — спул выключен
+	stopReplay context.CancelFunc
+	replayDone chan struct{}
 }
 
-func NewProducer(cfg *config.Kafka) *Producer {
+func NewProducer(cfg *config.Kafka) (*Producer, error) {
+	ver := smsSchemaVer(cfg)
+	if _, ok := smsCodecs[ver]; !ok {
+		return nil, fmt.Errorf("KAFKA_PRODUCER_SMS_SCHEMA_VER: %w %q", ErrUnsupportedSchema, ver)
+	}
+
 	d := newDialer(cfg)
 	w := newWriter(cfg, d)
-	return &Producer{w: w}
+	p := &Producer{w: w, smsSchema: ver, source: eventSource(cfg)}
+	p.aq = newAsyncQueue(p, cfg, d)
+
+	if dir := strings.TrimSpace(cfg.Producer.SpoolDir); dir != "" {
+		sp, err := openSpool(dir, cfg.Producer.SpoolMaxBytes)
+		if err != nil {
+			_ = errors.Join(w.Close(), p.aq.w.Close())
+			return nil, err
+		}
+		p.spool = sp
+
+		interval := cfg.Producer.SpoolReplayInterval
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stopReplay, p.replayDone = cancel, make(chan struct{})
+		go p.replayLoop(ctx, interval)
+	}
+	return p, nil
 }
 
+// ProduceSMS пишет sms в версии схемы KAFKA_PRODUCER_SMS_SCHEMA_VER.
+// Версии, которые консьюмер не умеет читать, отклоняются с ErrUnsupportedSchema.
 func (p *Producer) ProduceSMS(ctx context.Context, sms SMS) error {
-	payload, err := json.Marshal(sms)
+	msg, err := smsMessage(p.source, p.smsSchema, sms)
 	if err != nil {
 		return err
 	}
+	return p.send(ctx, msg)
+}
 
-	msg := kafka.Message{
-		Key:   []byte(sms.UserID.String()),
-		Value: payload,
-		Time:  time.Now().UTC(),
-		Headers: []kafka.Header{
-			{Key: "content-type", Value: []byte("application/json")},
-			{Key: "event-type",   Value: []byte("sms")},
-			{Key: "schema-ver",   Value: []byte("1")},
-		},
+// send — общий путь синхронной отправки: span публикации, трейс в
+// заголовках, запись (со спулом, если включён).
+func (p *Producer) send(ctx context.Context, msg kafka.Message) error {
+	ctx, span := startPublishSpan(ctx, p.w.Topic, &msg)
+	err := p.write(ctx, msg)
+	endSpan(span, err)
+	return err
+}
+
+// write пишет в Kafka. Со спулом: если запись не удалась — сообщения
+// уходят на диск; пока спул не пуст — сразу туда, чтобы не нарушить порядок.
+func (p *Producer) write(ctx context.Context, msgs ...kafka.Message) error {
+	if p.spool == nil {
+		return p.w.WriteMessages(ctx, msgs...)
 	}
+	if !p.spool.empty() {
+		return p.spool.append(msgs...)
+	}
+
+	err := p.w.WriteMessages(ctx, msgs...)
+	if err == nil {
+		return nil
+	}
+	if serr := p.spool.append(msgs...); serr != nil {
+		return errors.Join(err, serr)
+	}
+	log.Warn().Err(err).Int("messages", len(msgs)).Msg("kafka write failed; spooled to disk")
+	return nil
+}
+
+// replayLoop периодически проигрывает спул, пока брокер не примет всё.
+func (p *Producer) replayLoop(ctx context.Context, interval time.Duration) {
+	defer close(p.replayDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.spool.empty() {
+				continue
+			}
+			if err := p.spool.replay(ctx, p.w.WriteMessages); err != nil {
+				log.Warn().Err(err).Msg("spool replay failed; will retry")
+				continue
+			}
+			log.Info().Msg("spool replayed")
+		}
+	}
+}
 
-	return p.w.WriteMessages(ctx, msg)
+// smsMessage — сообщение Kafka для sms в версии схемы ver.
+func smsMessage(source, ver string, sms SMS) (kafka.Message, error) {
+	e := NewEnvelope(defaultEventType, sms.UserID.String(), sms)
+	e.SchemaVer = ver
+	return e.message(source, func(s SMS) ([]byte, error) { return EncodeSMS(ver, s) })
+}
+
+func smsSchemaVer(cfg *config.Kafka) string {
+	if ver := strings.TrimSpace(cfg.Producer.SMSSchemaVer); ver != "" {
+		return ver
+	}
+	return "1"
 }
 
 func (p *Producer) Close() error {
 	log.Info().Msg("closing kafka producer")
-	return p.w.Close()
+	// закрытие async writer'а дожидается всех Completion — до закрытия спула
+	err := p.aq.w.Close()
+	if p.spool != nil {
+		p.stopReplay()
+		<-p.replayDone
+		err = errors.Join(err, p.spool.close())
+	}
+	return errors.Join(err, p.w.Close())
 }
 
 func newDialer(cfg *config.Kafka) *kafka.Dialer {
@@ -126,9 +212,9 @@ func splitCSV(v []string) []string {
 
 func pickAcks(s string) kafka.RequiredAcks {
 	switch strings.ToLower(strings.TrimSpace(s)) {
-	case "none":
+	case "none", "0":
 		return kafka.RequireNone
-	case "leader", "one":
+	case "leader", "one", "1":
 		return kafka.RequireOne
 	default:
 		return kafka.RequireAll
@@ -0,0 +1,30 @@
+package kafkaio
+
+import (
+	"errors"
+	"testing"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestPickAcks(t *testing.T) {
+	for in, want := range map[string]kafka.RequiredAcks{
+		"none": kafka.RequireNone, "0": kafka.RequireNone,
+		"leader": kafka.RequireOne, "one": kafka.RequireOne, "1": kafka.RequireOne,
+		"all": kafka.RequireAll, "-1": kafka.RequireAll, "": kafka.RequireAll,
+	} {
+		if got := pickAcks(in); got != want {
+			t.Errorf("pickAcks(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestNewProducer_RejectsUnsupportedSchemaVer(t *testing.T) {
+	cfg := &config.Kafka{}
+	cfg.Producer.SMSSchemaVer = "9"
+	if _, err := NewProducer(cfg); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Fatalf("want ErrUnsupportedSchema, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/logger"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки retry-тиров.
+const (
+	HeaderRetryAttempt   = "x-retry-attempt"    // номер тира, в который ушло сообщение (1..N)
+	HeaderRetryNotBefore = "x-retry-not-before" // unix ms, раньше которого повтор не делаем
+)
+
+// retryTier — один уровень отложенной переотправки: <topic>.retry.<delay>.
+type retryTier struct {
+	topic string
+	delay time.Duration
+	r     reader
+}
+
+func retryTopic(base string, delay time.Duration) string {
+	return base + ".retry." + shortDuration(delay)
+}
+
+// shortDuration: 5m0s → 5m, 1h0m0s → 1h, 1m30s → 1m30s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// retryAttempt — сколько тиров сообщение уже прошло (0 — из основного топика).
+func retryAttempt(h []kafka.Header) int {
+	v, ok := headerValue(h, HeaderRetryAttempt)
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func retryNotBefore(h []kafka.Header) time.Time {
+	v, ok := headerValue(h, HeaderRetryNotBefore)
+	if !ok {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// reroute уводит неуспешное сообщение в следующий retry-тир, а после
+// последнего тира — в DLQ. Возвращает true, если сообщение принято
+// служебным топиком и его оффсет можно коммитить.
+func (c *Consumer) reroute(ctx context.Context, m kafka.Message, reason string) bool {
+	attempt := retryAttempt(m.Headers)
+	if attempt >= len(c.tiers) {
+		return c.deadLetter(ctx, m, reason)
+	}
+
+	t := c.tiers[attempt]
+	notBefore := time.Now().Add(t.delay).UnixMilli()
+	err := c.rp.publish(ctx, t.topic, m,
+		kafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt + 1))},
+		kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(strconv.FormatInt(notBefore, 10))},
+		kafka.Header{Key: HeaderDLQReason, Value: []byte(reason)},
+	)
+	if err != nil {
+		log.Error().Err(err).Str("retry_topic", t.topic).Int("partition", m.Partition).Int64("offset", m.Offset).
+			Msg("retry publish failed")
+		return false
+	}
+	log.Warn().Str("retry_topic", t.topic).Int("attempt", attempt+1).Int("partition", m.Partition).
+		Int64("offset", m.Offset).Msg("message scheduled for retry")
+	return true
+}
+
+// runRetry читает retry-тир по одному сообщению, дожидается
+// x-retry-not-before и отдаёт сообщение тому же handler'у.
+// У всех сообщений тира одна и та же задержка, поэтому они приходят
+// почти в порядке x-retry-not-before и ожидание текущего не тормозит остальные.
+func (c *Consumer) runRetry(ctx context.Context, t retryTier, handler Handler) {
+	for {
+		m, err := t.r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Str("topic", t.topic).Msg("retry fetch failed; continue")
+			if !sleepCtx(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+
+		c.metrics.observeLag(m)
+		if !sleepCtx(ctx, time.Until(retryNotBefore(m.Headers))) {
+			return
+		}
+
+		it, err := c.decode(m)
+		if errors.Is(err, errUnknownEvent) {
+			// та же политика, что и в PollBatch
+			c.metrics.skip(ctx, t.topic, skipUnknown)
+			reason := err.Error()
+			if c.router.policy == UnknownDLQ && !c.untilSettled(ctx, func() bool { return c.deadLetter(ctx, m, reason) }) {
+				return
+			}
+			c.commitRetry(ctx, t, m)
+			continue
+		}
+		if err != nil {
+			c.metrics.decodeFailed(ctx, t.topic)
+			reason := "decode: " + err.Error()
+			if !c.untilSettled(ctx, func() bool { return c.deadLetter(ctx, m, reason) }) {
+				return
+			}
+			c.commitRetry(ctx, t, m)
+			continue
+		}
+
+		items := []BatchItem{it}
+		spanCtx, span := startProcessSpan(ctx, t.topic, items)
+		started := time.Now()
+		okIdx, err := handler(spanCtx, items)
+		c.metrics.handled(ctx, t.topic, len(items), started, err)
+		if err != nil {
+			logger.Ctx(spanCtx).Error().Err(err).Str("topic", t.topic).Msg("handler error")
+		}
+		_, stuck := c.settle(spanCtx, handler, items, okIdx, err)
+		endSpan(span, err)
+		for _, h := range stuck {
+			if !c.untilSettled(ctx, func() bool { return c.reroute(ctx, h.m, h.reason) }) {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return // settle прерван: сообщение не обработано и не переложено
+		}
+		c.commitRetry(ctx, t, m)
+	}
+}
+
+// commitRetry коммитит сообщение тира; ошибка только логируется —
+// сообщение придёт ещё раз.
+func (c *Consumer) commitRetry(ctx context.Context, t retryTier, m kafka.Message) {
+	if err := t.r.CommitMessages(ctx, m); err != nil {
+		c.metrics.commitFailed(ctx, t.topic)
+		log.Warn().Err(err).Str("topic", t.topic).Msg("commit retry message failed")
+	}
+}
+
+// untilSettled повторяет publish с паузой c.backoff, пока сообщение не
+// примут. Тир читается по одному сообщению, и коммит следующего сдвинул бы
+// группу и через это, поэтому дальше не идём. false — ctx отменён.
+func (c *Consumer) untilSettled(ctx context.Context, publish func() bool) bool {
+	for !publish() {
+		if !sleepCtx(ctx, c.backoff) {
+			return false
+		}
+	}
+	return true
+}
+
+// sleepCtx спит d или до отмены ctx. Возвращает false, если ctx отменён.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
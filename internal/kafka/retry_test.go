@@ -0,0 +1,187 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestRetryTopic_ShortDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * time.Second:        "sms.retry.30s",
+		5 * time.Minute:         "sms.retry.5m",
+		time.Hour:               "sms.retry.1h",
+		90 * time.Second:        "sms.retry.1m30s",
+		time.Hour + time.Minute: "sms.retry.1h1m",
+	} {
+		if got := retryTopic("sms", d); got != want {
+			t.Errorf("retryTopic(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestReroute_WalksTiersThenDLQ(t *testing.T) {
+	w := &fakeWriter{}
+	c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+	c.tiers = []retryTier{
+		{topic: "sms.retry.30s", delay: 30 * time.Second},
+		{topic: "sms.retry.5m", delay: 5 * time.Minute},
+	}
+	ctx := context.Background()
+
+	m := smsMsg(7, goodSMS)
+	for i, want := range []string{"sms.retry.30s", "sms.retry.5m", "sms.dlq"} {
+		before := time.Now()
+		if !c.reroute(ctx, m, "boom") {
+			t.Fatalf("step %d: reroute failed", i)
+		}
+		out := w.written()[i]
+		if out.Topic != want {
+			t.Fatalf("step %d: topic %q, want %q", i, out.Topic, want)
+		}
+		if v, _ := headerValue(out.Headers, HeaderOriginalOffset); v != "7" {
+			t.Fatalf("step %d: x-original-offset = %q", i, v)
+		}
+		if want != "sms.dlq" {
+			if got := retryAttempt(out.Headers); got != i+1 {
+				t.Fatalf("step %d: attempt %d, want %d", i, got, i+1)
+			}
+			if nb := retryNotBefore(out.Headers); nb.Before(before.Add(c.tiers[i].delay).Truncate(time.Millisecond)) {
+				t.Fatalf("step %d: not-before %s is earlier than the tier delay", i, nb)
+			}
+		}
+		// так сообщение прочитает следующий тир
+		m = out
+		m.Offset = int64(100 + i)
+	}
+}
+
+func TestRunRetry_TierWaitsUntilFailedMessageIsRerouted(t *testing.T) {
+	tier := &fakeReader{}
+	for off := int64(0); off < 2; off++ {
+		m := smsMsg(off, goodSMS)
+		m.Topic = "sms.retry.30s"
+		m.Headers = []kafka.Header{{Key: HeaderRetryAttempt, Value: []byte("1")}}
+		tier.msgs = append(tier.msgs, m)
+	}
+	w := &fakeWriter{err: errors.New("broker down")}
+	c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+	c.tiers = []retryTier{{topic: "sms.retry.30s", delay: 30 * time.Second, r: tier}}
+
+	handled := make(chan int64, 10)
+	handler := func(_ context.Context, items []BatchItem) ([]int, error) {
+		off := items[0].commit.Offset
+		handled <- off
+		if off == 0 {
+			return nil, errors.New("still failing")
+		}
+		return []int{0}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.runRetry(ctx, c.tiers[0], handler)
+	}()
+	defer func() { cancel(); <-done }()
+
+	if off := <-handled; off != 0 {
+		t.Fatalf("handled offset %d first", off)
+	}
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case off := <-handled:
+		t.Fatalf("offset %d handled while offset 0 is not rerouted", off)
+	default:
+	}
+	if got := tier.lastCommit(0); got != -1 {
+		t.Fatalf("tier committed offset %d while offset 0 is not rerouted", got)
+	}
+
+	w.setErr(nil)
+	if off := <-handled; off != 1 {
+		t.Fatalf("handled offset %d, want 1", off)
+	}
+	waitFor(t, func() bool { return tier.lastCommit(0) == 1 })
+
+	out := w.written()
+	if len(out) != 1 || out[0].Topic != "sms.dlq" {
+		t.Fatalf("rerouted: %+v", out)
+	}
+	if v, _ := headerValue(out[0].Headers, HeaderOriginalOffset); v != strconv.Itoa(0) {
+		t.Fatalf("x-original-offset = %q", v)
+	}
+}
+
+func TestRunRetry_DecodeFailureWaitsForDLQ(t *testing.T) {
+	bad := smsMsg(0, "{broken")
+	bad.Topic = "sms.retry.30s"
+	tier := &fakeReader{msgs: []kafka.Message{bad}}
+	w := &fakeWriter{err: errors.New("broker down")}
+	c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+	c.tiers = []retryTier{{topic: "sms.retry.30s", delay: 30 * time.Second, r: tier}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.runRetry(ctx, c.tiers[0], func(context.Context, []BatchItem) ([]int, error) { return nil, nil })
+	}()
+	defer func() { cancel(); <-done }()
+
+	time.Sleep(20 * time.Millisecond)
+	if got := tier.lastCommit(0); got != -1 {
+		t.Fatalf("undecodable message committed before reaching the DLQ: offset %d", got)
+	}
+	w.setErr(nil)
+	waitFor(t, func() bool { return tier.lastCommit(0) == 0 })
+	if out := w.written(); len(out) != 1 || out[0].Topic != "sms.dlq" {
+		t.Fatalf("dlq writes: %+v", out)
+	}
+}
+
+func TestRunRetry_UnknownEventFollowsPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy UnknownPolicy
+		dlq    int
+	}{{UnknownSkip, 0}, {UnknownDLQ, 1}} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			m := rawMsg("payment", `{}`)
+			m.Topic = "sms.retry.30s"
+			tier := &fakeReader{msgs: []kafka.Message{m}}
+			w := &fakeWriter{}
+			c := newTestConsumer(t, &fakeReader{}, w, "sms.dlq")
+			c.router = NewRouter(tc.policy)
+			c.tiers = []retryTier{{topic: "sms.retry.30s", delay: 30 * time.Second, r: tier}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.runRetry(ctx, c.tiers[0], func(context.Context, []BatchItem) ([]int, error) { return nil, nil })
+			}()
+			defer func() { cancel(); <-done }()
+
+			waitFor(t, func() bool { return tier.lastCommit(0) == 0 })
+			if got := len(w.written()); got != tc.dlq {
+				t.Fatalf("dlq writes: %d, want %d", got, tc.dlq)
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,197 @@
+package kafkaio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// UnknownPolicy — что делать с событием, для которого нет маршрута.
+type UnknownPolicy string
+
+const (
+	UnknownSkip     UnknownPolicy = "skip"     // подтвердить и забыть
+	UnknownDLQ      UnknownPolicy = "dlq"      // переложить в DLQ
+	UnknownFallback UnknownPolicy = "fallback" // отдать fallback-handler'у
+)
+
+const (
+	HeaderEventType = "event-type"
+	HeaderSchemaVer = "schema-ver"
+
+	// события без заголовков считаются sms v1 — так писали старые продюсеры
+	defaultEventType = "sms"
+	defaultSchemaVer = "1"
+)
+
+var errUnknownEvent = errors.New("unknown event type")
+
+type routeKey struct{ eventType, schemaVer string }
+
+type route struct {
+	decode func([]byte) (any, error)
+	handle Handler
+}
+
+// Router — реестр обработчиков по паре (event-type, schema-ver).
+// Один Consumer с Router'ом может нести SMS, email и платёжные события.
+type Router struct {
+	routes   map[routeKey]route
+	policy   UnknownPolicy
+	fallback Handler
+}
+
+// NewRouter — policy "" — взять политику из KAFKA_CONSUMER_UNKNOWN_EVENTS
+// консьюмера, которому Router передан в StartRouter.
+func NewRouter(policy UnknownPolicy) *Router {
+	if policy != "" {
+		policy = parseUnknownPolicy(string(policy))
+	}
+	return &Router{routes: map[routeKey]route{}, policy: policy}
+}
+
+// parseUnknownPolicy — неизвестное значение считается skip.
+func parseUnknownPolicy(s string) UnknownPolicy {
+	switch p := UnknownPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case UnknownSkip, UnknownDLQ, UnknownFallback:
+		return p
+	default:
+		return UnknownSkip
+	}
+}
+
+// Route регистрирует "сырой" обработчик: decode кладёт результат в
+// BatchItem.Event, handler получает элементы как есть.
+func (r *Router) Route(eventType, schemaVer string, decode func([]byte) (any, error), h Handler) {
+	r.routes[newRouteKey(eventType, schemaVer)] = route{decode: decode, handle: h}
+}
+
+// Fallback — обработчик для событий без маршрута (политика UnknownFallback).
+// BatchItem.Event у таких элементов nil, тело доступно через Value().
+func (r *Router) Fallback(h Handler) { r.fallback = h }
+
+// Decoder — декодер тела события в T.
+type Decoder[T any] func([]byte) (T, error)
+
+// JSON — декодер по умолчанию.
+func JSON[T any]() Decoder[T] {
+	return func(b []byte) (T, error) {
+		var v T
+		err := json.Unmarshal(b, &v)
+		return v, err
+	}
+}
+
+// Register регистрирует типизированный обработчик. Индексы в okIdx
+// относятся к срезу events, Router сам переводит их в индексы батча.
+func Register[T any](r *Router, eventType, schemaVer string, dec Decoder[T], h func(context.Context, []T) ([]int, error)) {
+	r.Route(eventType, schemaVer,
+		func(b []byte) (any, error) { return dec(b) },
+		func(ctx context.Context, items []BatchItem) ([]int, error) {
+			events := make([]T, len(items))
+			for i, it := range items {
+				events[i], _ = it.Event.(T)
+			}
+			return h(ctx, events)
+		},
+	)
+}
+
+// decode определяет маршрут по заголовкам и декодирует тело.
+// Для неизвестного типа: fallback — элемент без Event, иначе errUnknownEvent.
+func (r *Router) decode(m kafka.Message) (BatchItem, error) {
+	et, ver := eventOf(m.Headers)
+	it := BatchItem{EventType: et, SchemaVer: ver, commit: m}
+
+	rt, ok := r.routes[newRouteKey(et, ver)]
+	if !ok {
+		if r.policy == UnknownFallback {
+			return it, nil
+		}
+		return BatchItem{}, fmt.Errorf("%w: %s v%s", errUnknownEvent, et, ver)
+	}
+
+	ev, err := rt.decode(m.Value)
+	if err != nil {
+		return BatchItem{}, err
+	}
+	it.Event = ev
+	if sms, isSMS := ev.(SMS); isSMS {
+		it.SMS = sms
+	}
+	return it, nil
+}
+
+// Handle — Handler для Consumer.Start: делит батч по маршрутам (порядок
+// внутри маршрута сохраняется), вызывает их обработчики и собирает okIdx.
+func (r *Router) Handle(ctx context.Context, items []BatchItem) ([]int, error) {
+	type group struct {
+		h     Handler
+		items []BatchItem
+		idx   []int
+	}
+	groups := map[routeKey]*group{}
+	order := []routeKey{}
+
+	var okIdx []int
+	for i, it := range items {
+		k := newRouteKey(it.EventType, it.SchemaVer)
+		h := r.fallback
+		if rt, ok := r.routes[k]; ok {
+			h = rt.handle
+		}
+		if h == nil {
+			okIdx = append(okIdx, i) // fallback не задан — как skip
+			continue
+		}
+		g := groups[k]
+		if g == nil {
+			g = &group{h: h}
+			groups[k] = g
+			order = append(order, k)
+		}
+		g.items = append(g.items, it)
+		g.idx = append(g.idx, i)
+	}
+
+	var errs error
+	for _, k := range order {
+		g := groups[k]
+		ok, err := g.h(ctx, g.items)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s v%s: %w", k.eventType, k.schemaVer, err))
+		}
+		for _, i := range ok {
+			if i >= 0 && i < len(g.idx) {
+				okIdx = append(okIdx, g.idx[i])
+			}
+		}
+	}
+	return okIdx, errs
+}
+
+func newRouteKey(eventType, schemaVer string) routeKey {
+	return routeKey{
+		eventType: strings.ToLower(strings.TrimSpace(eventType)),
+		schemaVer: strings.TrimSpace(schemaVer),
+	}
+}
+
+func eventOf(h []kafka.Header) (eventType, schemaVer string) {
+	eventType, ok := headerValue(h, HeaderEventType)
+	if !ok || strings.TrimSpace(eventType) == "" {
+		eventType, ok = headerValue(h, HeaderCEType) // чужие CloudEvents-продюсеры
+	}
+	if !ok || strings.TrimSpace(eventType) == "" {
+		eventType = defaultEventType
+	}
+	schemaVer, ok = headerValue(h, HeaderSchemaVer)
+	if !ok || strings.TrimSpace(schemaVer) == "" {
+		schemaVer = defaultSchemaVer
+	}
+	return eventType, schemaVer
+}
@@ -0,0 +1,126 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type email struct {
+	To string `json:"to"`
+}
+
+func rawMsg(eventType, body string) kafka.Message {
+	return kafka.Message{
+		Headers: []kafka.Header{{Key: HeaderEventType, Value: []byte(eventType)}},
+		Value:   []byte(body),
+	}
+}
+
+func TestRouter_DispatchesByTypeAndMapsIndices(t *testing.T) {
+	r := NewRouter(UnknownSkip)
+	Register(r, "sms", "1", JSON[SMS](), func(_ context.Context, ev []SMS) ([]int, error) {
+		return []int{1}, errors.New("first sms failed") // только второй sms
+	})
+	Register(r, "email", "1", JSON[email](), func(_ context.Context, ev []email) ([]int, error) {
+		if len(ev) != 1 || ev[0].To != "a@b.c" {
+			t.Fatalf("unexpected email events: %+v", ev)
+		}
+		return []int{0}, nil
+	})
+
+	var items []BatchItem
+	for _, m := range []kafka.Message{
+		rawMsg("sms", `{"phone":"1"}`),
+		rawMsg("email", `{"to":"a@b.c"}`),
+		rawMsg("sms", `{"phone":"2"}`),
+	} {
+		it, err := r.decode(m)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		items = append(items, it)
+	}
+	if items[2].SMS.Phone != "2" {
+		t.Fatalf("sms must be mirrored into BatchItem.SMS, got %+v", items[2])
+	}
+
+	okIdx, err := r.Handle(context.Background(), items)
+	if err == nil {
+		t.Fatal("expected handler error to be propagated")
+	}
+	sort.Ints(okIdx)
+	if len(okIdx) != 2 || okIdx[0] != 1 || okIdx[1] != 2 {
+		t.Fatalf("okIdx: want [1 2], got %v", okIdx)
+	}
+}
+
+func TestRouter_UnknownPolicy(t *testing.T) {
+	skip := NewRouter(UnknownSkip)
+	if _, err := skip.decode(rawMsg("payment", `{}`)); !errors.Is(err, errUnknownEvent) {
+		t.Fatalf("skip policy: want errUnknownEvent, got %v", err)
+	}
+
+	fb := NewRouter(UnknownFallback)
+	var got []BatchItem
+	fb.Fallback(func(_ context.Context, items []BatchItem) ([]int, error) {
+		got = items
+		return []int{0}, nil
+	})
+	it, err := fb.decode(rawMsg("payment", `{"amount":1}`))
+	if err != nil {
+		t.Fatalf("fallback decode: %v", err)
+	}
+	okIdx, _ := fb.Handle(context.Background(), []BatchItem{it})
+	if len(okIdx) != 1 || len(got) != 1 || string(got[0].Value()) != `{"amount":1}` {
+		t.Fatalf("fallback not invoked with raw value: ok=%v got=%+v", okIdx, got)
+	}
+}
+
+func TestStartRouter_TakesUnknownPolicyFromConfig(t *testing.T) {
+	c := newTestConsumer(t, &fakeReader{}, nil, "")
+	c.unknown = parseUnknownPolicy(" DLQ ")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := NewRouter("")
+	c.StartRouter(ctx, r)
+	if r.policy != UnknownDLQ {
+		t.Fatalf("policy = %q, want dlq", r.policy)
+	}
+
+	explicit := NewRouter(UnknownFallback)
+	c.StartRouter(ctx, explicit)
+	if explicit.policy != UnknownFallback {
+		t.Fatalf("explicit policy overridden: %q", explicit.policy)
+	}
+}
+
+func TestPollBatch_UnknownEventHeldUntilDLQAccepts(t *testing.T) {
+	unknown := rawMsg("payment", `{}`)
+	unknown.Topic = "sms"
+	r := &fakeReader{msgs: []kafka.Message{unknown}}
+	w := &fakeWriter{err: errors.New("broker down")}
+	c := newTestConsumer(t, r, w, "sms.dlq")
+	c.router = NewRouter(UnknownDLQ)
+	ctx := context.Background()
+
+	if items, _ := c.PollBatch(ctx, 0); len(items) != 0 {
+		t.Fatalf("unknown event passed to handler: %+v", items)
+	}
+	if got := r.lastCommit(0); got != -1 {
+		t.Fatalf("unknown event committed before reaching the DLQ: offset %d", got)
+	}
+
+	w.setErr(nil)
+	c.retryHeld(ctx)
+	if out := w.written(); len(out) != 1 || out[0].Topic != "sms.dlq" {
+		t.Fatalf("dlq writes: %+v", out)
+	}
+	if got := r.lastCommit(0); got != 0 {
+		t.Fatalf("committed offset %d, want 0", got)
+	}
+}
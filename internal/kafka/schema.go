@@ -0,0 +1,137 @@
+package kafkaio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pay_flow_go/internal/pii"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+const (
+	SMSPriorityNormal = "normal"
+	SMSPriorityHigh   = "high"
+)
+
+// SMS — актуальная (v2) форма события. Все старые версии поднимаются до неё.
+type SMS struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Phone      string    `json:"phone"`
+	IIN        string    `json:"iin"`
+	Text       string    `json:"text"`
+	Sender     string    `json:"sender,omitempty"`
+	Priority   string    `json:"priority,omitempty"`
+	TemplateID string    `json:"template_id,omitempty"`
+	Locale     string    `json:"locale,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MarshalZerologObject — sms в логах: телефон, ИИН и текст маскируются (pii).
+func (s SMS) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("user_id", s.UserID.String()).
+		Str("phone", pii.Phone(s.Phone)).
+		Str("iin", pii.IIN(s.IIN)).
+		Str("text", pii.Text(s.Text)).
+		Str("priority", s.Priority).
+		Str("template_id", s.TemplateID)
+}
+
+// SMSV1 — исходная схема, без priority/template_id/locale.
+type SMSV1 struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Phone     string    `json:"phone"`
+	IIN       string    `json:"iin"`
+	Text      string    `json:"text"`
+	Sender    string    `json:"sender,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Upcast поднимает v1 до актуальной схемы.
+func (v SMSV1) Upcast() SMS {
+	return SMS{
+		UserID:    v.UserID,
+		Phone:     v.Phone,
+		IIN:       v.IIN,
+		Text:      v.Text,
+		Sender:    v.Sender,
+		Priority:  SMSPriorityNormal,
+		CreatedAt: v.CreatedAt,
+	}
+}
+
+// smsCodec — чтение и запись одной версии схемы.
+type smsCodec struct {
+	decode func([]byte) (SMS, error)
+	encode func(SMS) ([]byte, error)
+}
+
+// smsCodecs — версии, которые этот билд умеет читать. Продюсер пишет
+// только их, поэтому новая версия сначала появляется здесь (выкатка
+// консьюмеров), и лишь потом включается KAFKA_PRODUCER_SMS_SCHEMA_VER.
+var smsCodecs = map[string]smsCodec{
+	"1": {
+		decode: func(b []byte) (SMS, error) {
+			var v SMSV1
+			if err := json.Unmarshal(b, &v); err != nil {
+				return SMS{}, err
+			}
+			return v.Upcast(), nil
+		},
+		encode: func(s SMS) ([]byte, error) {
+			return json.Marshal(SMSV1{
+				UserID:    s.UserID,
+				Phone:     s.Phone,
+				IIN:       s.IIN,
+				Text:      s.Text,
+				Sender:    s.Sender,
+				CreatedAt: s.CreatedAt,
+			})
+		},
+	},
+	"2": {
+		decode: func(b []byte) (SMS, error) {
+			var v SMS
+			if err := json.Unmarshal(b, &v); err != nil {
+				return SMS{}, err
+			}
+			if v.Priority == "" {
+				v.Priority = SMSPriorityNormal
+			}
+			return v, nil
+		},
+		encode: func(s SMS) ([]byte, error) { return json.Marshal(s) },
+	},
+}
+
+// DecodeSMS читает тело версии ver и поднимает его до SMS.
+func DecodeSMS(ver string, b []byte) (SMS, error) {
+	c, ok := smsCodecs[strings.TrimSpace(ver)]
+	if !ok {
+		return SMS{}, fmt.Errorf("sms: %w %q", ErrUnsupportedSchema, ver)
+	}
+	return c.decode(b)
+}
+
+// EncodeSMS сериализует sms в версии ver (поля новее ver отбрасываются).
+func EncodeSMS(ver string, sms SMS) ([]byte, error) {
+	c, ok := smsCodecs[strings.TrimSpace(ver)]
+	if !ok {
+		return nil, fmt.Errorf("sms: %w %q", ErrUnsupportedSchema, ver)
+	}
+	return c.encode(sms)
+}
+
+// RegisterSMS регистрирует h для всех поддерживаемых версий sms.
+func RegisterSMS(r *Router, h func(context.Context, []SMS) ([]int, error)) {
+	for ver := range smsCodecs {
+		Register(r, defaultEventType, ver, func(b []byte) (SMS, error) { return DecodeSMS(ver, b) }, h)
+	}
+}
@@ -0,0 +1,116 @@
+package kafkaio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"pay_flow_go/internal/config"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+func sampleSMS() SMS {
+	return SMS{
+		UserID:     uuid.MustParse("11111111-2222-3333-4444-555555555555"),
+		Phone:      "+77011234567",
+		IIN:        "990101300012",
+		Text:       "code 1234",
+		Sender:     "PAY",
+		Priority:   SMSPriorityHigh,
+		TemplateID: "otp",
+		Locale:     "kk",
+		CreatedAt:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestDecodeSMS_V1Upcast(t *testing.T) {
+	b := []byte(`{"user_id":"11111111-2222-3333-4444-555555555555","phone":"+77011234567","iin":"990101300012","text":"hi","created_at":"2025-01-02T03:04:05Z"}`)
+	got, err := DecodeSMS("1", b)
+	if err != nil {
+		t.Fatalf("decode v1: %v", err)
+	}
+	if got.Phone != "+77011234567" || got.Text != "hi" {
+		t.Fatalf("v1 fields lost: %+v", got)
+	}
+	if got.Priority != SMSPriorityNormal || got.TemplateID != "" || got.Locale != "" {
+		t.Fatalf("v1 upcast defaults: %+v", got)
+	}
+}
+
+func TestDecodeSMS_V2RoundTrip(t *testing.T) {
+	want := sampleSMS()
+	b, err := EncodeSMS("2", want)
+	if err != nil {
+		t.Fatalf("encode v2: %v", err)
+	}
+	got, err := DecodeSMS("2", b)
+	if err != nil {
+		t.Fatalf("decode v2: %v", err)
+	}
+	if got != want {
+		t.Fatalf("v2 round trip:\n got  %+v\n want %+v", got, want)
+	}
+}
+
+func TestDecodeSMS_V2DefaultsPriority(t *testing.T) {
+	got, err := DecodeSMS("2", []byte(`{"phone":"+77011234567"}`))
+	if err != nil {
+		t.Fatalf("decode v2: %v", err)
+	}
+	if got.Priority != SMSPriorityNormal {
+		t.Fatalf("priority: want %q, got %q", SMSPriorityNormal, got.Priority)
+	}
+}
+
+func TestEncodeSMS_V1DropsNewFields(t *testing.T) {
+	b, err := EncodeSMS("1", sampleSMS())
+	if err != nil {
+		t.Fatalf("encode v1: %v", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"priority", "template_id", "locale"} {
+		if _, ok := raw[k]; ok {
+			t.Fatalf("v1 payload must not carry %q: %s", k, b)
+		}
+	}
+}
+
+func TestSMSSchema_UnsupportedVersion(t *testing.T) {
+	if _, err := EncodeSMS("3", sampleSMS()); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Fatalf("encode v3: want ErrUnsupportedSchema, got %v", err)
+	}
+	if _, err := DecodeSMS("3", []byte(`{}`)); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Fatalf("decode v3: want ErrUnsupportedSchema, got %v", err)
+	}
+}
+
+func TestSMSSchema_DefaultVersionReadable(t *testing.T) {
+	ver := smsSchemaVer(&config.Kafka{})
+	if _, ok := smsCodecs[ver]; !ok {
+		t.Fatalf("default schema %q has no codec", ver)
+	}
+}
+
+func TestSMS_LogMasksPII(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+	l.Info().Object("sms", SMS{Phone: "+77011234567", IIN: "990101300012", Text: "code 1234"}).Msg("")
+
+	out := buf.String()
+	for _, leak := range []string{"+77011234567", "990101300012", "code 1234"} {
+		if strings.Contains(out, leak) {
+			t.Fatalf("log leaks %q: %s", leak, out)
+		}
+	}
+	if !strings.Contains(out, "+7701***4567") || !strings.Contains(out, "9901******12") {
+		t.Fatalf("masked values missing: %s", out)
+	}
+}
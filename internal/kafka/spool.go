@@ -0,0 +1,341 @@
+package kafkaio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+var ErrSpoolFull = errors.New("kafka spool is full")
+
+const (
+	spoolSegmentBytes = 8 << 20  // ротация сегмента
+	spoolMaxFrame     = 64 << 20 // больше — заведомо битый кадр
+	spoolReplayChunk  = 100      // сообщений за один WriteMessages при проигрывании
+	spoolSegExt       = ".seg"
+	spoolCheckpoint   = "checkpoint"
+)
+
+// spool — write-ahead журнал на диске для сообщений, которые не удалось
+// записать в Kafka. Сообщения дописываются в сегменты
+// <dir>/<N>.seg кадрами [len u32][crc32 u32][json], а проигрываются
+// строго по порядку. Позиция проигрывания хранится в <dir>/checkpoint,
+// поэтому после рестарта ничего не теряется и почти ничего не дублируется.
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu     sync.Mutex
+	active *os.File
+	seq    int64 // номер активного сегмента; номера не переиспользуются
+	size   int64 // суммарный размер сегментов на диске
+}
+
+type spoolRecord struct {
+	Topic   string         `json:"topic,omitempty"`
+	Key     []byte         `json:"key,omitempty"`
+	Value   []byte         `json:"value"`
+	Headers []outboxHeader `json:"headers,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxBytes: maxBytes}
+
+	segs, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segs {
+		fi, err := os.Stat(s.segPath(seq))
+		if err != nil {
+			return nil, err
+		}
+		s.size += fi.Size()
+		s.seq = seq
+	}
+	// checkpoint мог пережить удалённый сегмент (падение между удалением
+	// и очисткой) — новый сегмент не должен получить его номер
+	if cpSeq, _ := s.readCheckpoint(); cpSeq > s.seq {
+		s.seq = cpSeq
+	}
+	return s, nil
+}
+
+func (s *spool) segPath(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", seq, spoolSegExt))
+}
+
+// segments — номера сегментов по возрастанию.
+func (s *spool) segments() ([]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []int64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spoolSegExt) {
+			continue
+		}
+		seq, err := strconv.ParseInt(strings.TrimSuffix(name, spoolSegExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, seq)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out, nil
+}
+
+// empty — нет ли в спуле сообщений, ожидающих проигрывания.
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size == 0
+}
+
+// append дописывает сообщения в активный сегмент и делает fsync.
+func (s *spool) append(msgs ...kafka.Message) error {
+	var buf []byte
+	for _, m := range msgs {
+		rec := spoolRecord{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time}
+		for _, h := range m.Headers {
+			rec.Headers = append(rec.Headers, outboxHeader{Key: h.Key, Value: h.Value})
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		var hdr [8]byte
+		binary.BigEndian.PutUint32(hdr[0:4], uint32(len(b)))
+		binary.BigEndian.PutUint32(hdr[4:8], crc32.ChecksumIEEE(b))
+		buf = append(buf, hdr[:]...)
+		buf = append(buf, b...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.size+int64(len(buf)) > s.maxBytes {
+		return ErrSpoolFull
+	}
+	if s.active == nil || s.activeSize() >= spoolSegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.active.Write(buf); err != nil {
+		return err
+	}
+	if err := s.active.Sync(); err != nil {
+		return err
+	}
+	s.size += int64(len(buf))
+	return nil
+}
+
+func (s *spool) activeSize() int64 {
+	fi, err := s.active.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// rotate закрывает активный сегмент и открывает следующий. Под s.mu.
+func (s *spool) rotate() error {
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			return err
+		}
+		s.active = nil
+	}
+	s.seq++
+	f, err := os.OpenFile(s.segPath(s.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	s.active = f
+	return nil
+}
+
+// replay проигрывает спул по порядку через send. Полностью отправленные
+// сегменты удаляются, позиция внутри сегмента сохраняется в checkpoint.
+// Первая ошибка send останавливает проигрывание — остаток ждёт следующего раза.
+func (s *spool) replay(ctx context.Context, send func(context.Context, ...kafka.Message) error) error {
+	s.mu.Lock()
+	// запечатываем активный сегмент, чтобы читать только неизменяемые файлы
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.active = nil
+	}
+	segs, err := s.segments()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	cpSeq, cpPos := s.readCheckpoint()
+	for _, seq := range segs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var from int64
+		if seq == cpSeq {
+			from = cpPos
+		}
+		if err := s.replaySegment(ctx, seq, from, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *spool) replaySegment(ctx context.Context, seq, from int64, send func(context.Context, ...kafka.Message) error) error {
+	path := s.segPath(seq)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	pos := from
+	for {
+		msgs, n, rerr := readFrames(r, spoolReplayChunk)
+		if len(msgs) > 0 {
+			if err := send(ctx, msgs...); err != nil {
+				return err
+			}
+			pos += n
+			if err := s.writeCheckpoint(seq, pos); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			// битый хвост (обрыв записи при падении) — дальше в сегменте читать нечего
+			log.Error().Err(rerr).Str("segment", path).Int64("pos", pos).Msg("spool segment corrupted; dropping tail")
+			break
+		}
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	// checkpoint удаляем после сегмента: при падении между ними сегмент не
+	// проиграется заново, а номер из checkpoint openSpool не переиспользует
+	if err := os.Remove(filepath.Join(s.dir, spoolCheckpoint)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	s.mu.Lock()
+	s.size = max(s.size-fi.Size(), 0)
+	s.mu.Unlock()
+	return nil
+}
+
+// readFrames читает до limit кадров. Возвращает прочитанные байты.
+func readFrames(r *bufio.Reader, limit int) ([]kafka.Message, int64, error) {
+	var (
+		msgs []kafka.Message
+		n    int64
+	)
+	for len(msgs) < limit {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return msgs, n, io.EOF
+			}
+			return msgs, n, fmt.Errorf("read frame header: %w", err)
+		}
+		size := binary.BigEndian.Uint32(hdr[0:4])
+		if size > spoolMaxFrame {
+			return msgs, n, fmt.Errorf("frame size %d exceeds limit", size)
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return msgs, n, fmt.Errorf("read frame body: %w", err)
+		}
+		if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(hdr[4:8]) {
+			return msgs, n, errors.New("frame checksum mismatch")
+		}
+
+		var rec spoolRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return msgs, n, fmt.Errorf("decode frame: %w", err)
+		}
+		m := kafka.Message{Topic: rec.Topic, Key: rec.Key, Value: rec.Value, Time: rec.Time}
+		for _, h := range rec.Headers {
+			m.Headers = append(m.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+		}
+		msgs = append(msgs, m)
+		n += int64(len(hdr) + len(b))
+	}
+	return msgs, n, nil
+}
+
+func (s *spool) readCheckpoint() (seq, pos int64) {
+	b, err := os.ReadFile(filepath.Join(s.dir, spoolCheckpoint))
+	if err != nil {
+		return 0, 0
+	}
+	parts := strings.Fields(string(b))
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	seq, _ = strconv.ParseInt(parts[0], 10, 64)
+	pos, _ = strconv.ParseInt(parts[1], 10, 64)
+	return seq, pos
+}
+
+// writeCheckpoint атомарно (через rename) сохраняет позицию проигрывания.
+func (s *spool) writeCheckpoint(seq, pos int64) error {
+	tmp := filepath.Join(s.dir, spoolCheckpoint+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", seq, pos)), 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, spoolCheckpoint))
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
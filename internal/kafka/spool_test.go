@@ -0,0 +1,199 @@
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func spoolMsgs(from, to int) []kafka.Message {
+	var out []kafka.Message
+	for i := from; i < to; i++ {
+		out = append(out, kafka.Message{
+			Key:     []byte("k"),
+			Value:   []byte(strconv.Itoa(i)),
+			Headers: []kafka.Header{{Key: HeaderEventType, Value: []byte("sms")}},
+		})
+	}
+	return out
+}
+
+func TestSpool_SurvivesRestartAndReplaysInOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	sp, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.append(spoolMsgs(0, 150)...); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := sp.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// рестарт
+	sp, err = openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.empty() {
+		t.Fatal("spool must not be empty after restart")
+	}
+
+	var got []string
+	send := func(_ context.Context, msgs ...kafka.Message) error {
+		for _, m := range msgs {
+			if v, _ := headerValue(m.Headers, HeaderEventType); v != "sms" {
+				t.Fatalf("headers lost: %+v", m.Headers)
+			}
+			got = append(got, string(m.Value))
+		}
+		return nil
+	}
+	if err := sp.replay(context.Background(), send); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(got) != 150 {
+		t.Fatalf("replayed %d messages, want 150", len(got))
+	}
+	for i, v := range got {
+		if v != strconv.Itoa(i) {
+			t.Fatalf("order broken at %d: %s", i, v)
+		}
+	}
+	if !sp.empty() {
+		t.Fatal("spool must be empty after full replay")
+	}
+}
+
+func TestSpool_PartialReplayResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.append(spoolMsgs(0, 250)...); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	failSecond := func(_ context.Context, msgs ...kafka.Message) error {
+		calls++
+		if calls == 2 {
+			return errors.New("broker down")
+		}
+		return nil
+	}
+	if err := sp.replay(context.Background(), failSecond); err == nil {
+		t.Fatal("expected replay error")
+	}
+	_ = sp.close()
+
+	sp, err = openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first string
+	n := 0
+	if err := sp.replay(context.Background(), func(_ context.Context, msgs ...kafka.Message) error {
+		if first == "" {
+			first = string(msgs[0].Value)
+		}
+		n += len(msgs)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if first != strconv.Itoa(spoolReplayChunk) || n != 250-spoolReplayChunk {
+		t.Fatalf("resume: first=%s n=%d", first, n)
+	}
+}
+
+func TestSpool_MaxBytes(t *testing.T) {
+	sp, err := openSpool(t.TempDir(), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.close()
+	if err := sp.append(spoolMsgs(0, 10)...); !errors.Is(err, ErrSpoolFull) {
+		t.Fatalf("want ErrSpoolFull, got %v", err)
+	}
+}
+
+func TestSpool_TornTailIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.append(spoolMsgs(0, 3)...); err != nil {
+		t.Fatal(err)
+	}
+	_ = sp.close()
+
+	f, err := os.OpenFile(filepath.Join(dir, "0000000000000001.seg"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{0, 0, 0, 50, 1, 2}) // обрыв записи
+	_ = f.Close()
+
+	sp, _ = openSpool(dir, 0)
+	n := 0
+	if err := sp.replay(context.Background(), func(_ context.Context, msgs ...kafka.Message) error {
+		n += len(msgs)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("want 3 intact messages, got %d", n)
+	}
+}
+
+func TestSpool_CrashBetweenSegmentRemovalAndCheckpointReset(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.append(spoolMsgs(0, 50)...); err != nil {
+		t.Fatal(err)
+	}
+	noop := func(context.Context, ...kafka.Message) error { return nil }
+	if err := sp.replay(context.Background(), noop); err != nil {
+		t.Fatal(err)
+	}
+	_ = sp.close()
+
+	// сегмент 1 уже удалён, а checkpoint от него остался (падение перед очисткой)
+	if err := sp.writeCheckpoint(1, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	sp, err = openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.append(spoolMsgs(100, 120)...); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err := sp.replay(context.Background(), func(_ context.Context, msgs ...kafka.Message) error {
+		for _, m := range msgs {
+			got = append(got, string(m.Value))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 20 || got[0] != "100" {
+		t.Fatalf("stale checkpoint applied to a new segment: replayed %v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package kafkaio
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "pay_flow_go/internal/kafka"
+
+func tracer() trace.Tracer { return otel.Tracer(tracerName) }
+
+// headerCarrier — propagation.TextMapCarrier поверх заголовков Kafka
+// (traceparent, tracestate, baggage).
+type headerCarrier struct{ h *[]kafka.Header }
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := headerValue(*c.h, key)
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	*c.h = mergeHeaders(*c.h, []kafka.Header{{Key: key, Value: []byte(value)}})
+}
+
+func (c headerCarrier) Keys() []string {
+	out := make([]string, 0, len(*c.h))
+	for _, hd := range *c.h {
+		out = append(out, hd.Key)
+	}
+	return out
+}
+
+// injectTrace пишет контекст трейса из ctx в заголовки сообщения.
+func injectTrace(ctx context.Context, m *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{h: &m.Headers})
+}
+
+// remoteSpan — span context продюсера из заголовков сообщения.
+func remoteSpan(m kafka.Message) trace.SpanContext {
+	h := m.Headers
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{h: &h})
+	return trace.SpanContextFromContext(ctx)
+}
+
+// startPublishSpan — span отправки сообщения; его контекст уходит в заголовки.
+func startPublishSpan(ctx context.Context, topic string, m *kafka.Message) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingMessagePayloadSizeBytes(len(m.Value)),
+		),
+	)
+	injectTrace(ctx, m)
+	return ctx, span
+}
+
+// startProcessSpan — span обработки. Для одного сообщения он дочерний к
+// span'у продюсера, для батча — корневой со ссылками (links) на каждого.
+func startProcessSpan(ctx context.Context, topic string, items []BatchItem) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(len(items)),
+		),
+	}
+
+	if len(items) == 1 {
+		if sc := items[0].spanContext(); sc.IsValid() {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+		}
+		m := items[0].commit
+		opts = append(opts, trace.WithAttributes(
+			semconv.MessagingKafkaDestinationPartition(m.Partition),
+			semconv.MessagingKafkaMessageOffset(int(m.Offset)),
+		))
+	} else {
+		links := make([]trace.Link, 0, len(items))
+		for _, it := range items {
+			if sc := it.spanContext(); sc.IsValid() {
+				links = append(links, trace.Link{
+					SpanContext: sc,
+					Attributes: []attribute.KeyValue{
+						semconv.MessagingKafkaDestinationPartition(it.commit.Partition),
+						semconv.MessagingKafkaMessageOffset(int(it.commit.Offset)),
+					},
+				})
+			}
+		}
+		opts = append(opts, trace.WithLinks(links...), trace.WithNewRoot())
+	}
+
+	return tracer().Start(ctx, "process "+topic, opts...)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
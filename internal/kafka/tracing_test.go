@@ -0,0 +1,35 @@
+package kafkaio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation_HeadersRoundTrip(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	m := kafka.Message{Headers: []kafka.Header{{Key: HeaderEventType, Value: []byte("sms")}}}
+	injectTrace(ctx, &m)
+	if _, ok := headerValue(m.Headers, "traceparent"); !ok {
+		t.Fatalf("traceparent not injected: %+v", m.Headers)
+	}
+
+	got := BatchItem{commit: m}.spanContext()
+	if got.TraceID() != sc.TraceID() || got.SpanID() != sc.SpanID() || !got.IsRemote() {
+		t.Fatalf("extracted span context mismatch: %+v", got)
+	}
+}
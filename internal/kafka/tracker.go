@@ -0,0 +1,196 @@
+package kafkaio
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// maxAckedPerPartition — сколько подтверждений партиция держит за
+// застрявшим pending-оффсетом (см. evict).
+const maxAckedPerPartition = 10_000
+
+// offsetTracker — "pending offsets" по партициям, живущие между тиками.
+// Каждое выбранное сообщение сначала pending, после подтверждения — acked.
+// Коммитится максимальный acked-оффсет ниже самого раннего pending, так
+// что успехи за "дыркой" не теряются: они уйдут в коммит, как только
+// дырка закроется (успех, retry-тир или DLQ).
+type offsetTracker struct {
+	mu       sync.Mutex
+	parts    map[int]*partOffsets
+	maxAcked int
+}
+
+type partOffsets struct {
+	committed int64 // последний закоммиченный оффсет; -1 — ещё не коммитили
+	next      int64 // следующий ожидаемый при выборке оффсет; -1 — ещё не выбирали
+	pending   map[int64]struct{}
+	acked     map[int64]kafka.Message
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{parts: map[int]*partOffsets{}, maxAcked: maxAckedPerPartition}
+}
+
+func (t *offsetTracker) part(p int) *partOffsets {
+	po := t.parts[p]
+	if po == nil {
+		po = &partOffsets{committed: -1, next: -1, pending: map[int64]struct{}{}, acked: map[int64]kafka.Message{}}
+		t.parts[p] = po
+	}
+	return po
+}
+
+// track регистрирует выбранное сообщение. Возвращает false, если оно уже
+// было подтверждено раньше (повторная выборка после ребаланса) —
+// такое сообщение не нужно снова отдавать handler'у.
+func (t *offsetTracker) track(m kafka.Message) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	po := t.part(m.Partition)
+	if po.next >= 0 && m.Offset != po.next {
+		t.resync(m, po)
+	}
+	po.next = m.Offset + 1
+
+	if m.Offset <= po.committed {
+		return false
+	}
+	if _, done := po.acked[m.Offset]; done {
+		return false
+	}
+	po.pending[m.Offset] = struct{}{}
+	return true
+}
+
+// resync — выборка пошла не с ожидаемого оффсета: партиция вернулась
+// после ребаланса (или reader сдвинули). Всё pending ниже m.Offset здесь
+// уже не обработается — его закоммитил другой член группы, — и без
+// сброса оно навсегда держало бы watermark. Под mu.
+func (t *offsetTracker) resync(m kafka.Message, po *partOffsets) {
+	dropped := 0
+	for off := range po.pending {
+		if off < m.Offset {
+			delete(po.pending, off)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.Warn().Int("partition", m.Partition).Int64("expected", po.next).Int64("fetched", m.Offset).
+			Int("dropped_pending", dropped).Msg("partition offset jumped; forgetting stale pending offsets")
+	}
+}
+
+// ack отмечает сообщения как успешно обработанные.
+func (t *offsetTracker) ack(msgs ...kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range msgs {
+		po := t.part(m.Partition)
+		if m.Offset <= po.committed {
+			continue
+		}
+		delete(po.pending, m.Offset)
+		po.acked[m.Offset] = m
+		if len(po.acked) > t.maxAcked {
+			t.evict(m.Partition, po)
+		}
+	}
+}
+
+// evict не даёт acked расти без границ, когда pending-оффсет застрял
+// (например, без DLQ сообщение ждёт рестарта): забывает самые старые
+// подтверждения за ним, оставляя 3/4 лимита. Watermark — максимальный
+// acked, поэтому коммит от этого не меняется, а забытые сообщения после
+// ребаланса или рестарта просто будут обработаны ещё раз (at-least-once). Под mu.
+func (t *offsetTracker) evict(partition int, po *partOffsets) {
+	low := po.lowPending()
+	if low < 0 {
+		return // всё подтверждённое уйдёт в ближайший коммит
+	}
+	var above []int64
+	for off := range po.acked {
+		if off > low {
+			above = append(above, off)
+		}
+	}
+	n := min(len(po.acked)-t.maxAcked*3/4, len(above))
+	if n <= 0 {
+		return
+	}
+	slices.Sort(above)
+	for _, off := range above[:n] {
+		delete(po.acked, off)
+	}
+	log.Warn().Int("partition", partition).Int64("pending_offset", low).Int("evicted", n).
+		Msg("offset stuck; forgetting acks behind it, they will be redelivered after a restart")
+}
+
+// lowPending — минимальный pending-оффсет; -1 — таких нет.
+func (po *partOffsets) lowPending() int64 {
+	low := int64(-1)
+	for off := range po.pending {
+		if low < 0 || off < low {
+			low = off
+		}
+	}
+	return low
+}
+
+// watermarks — по сообщению на партицию, которое можно коммитить сейчас:
+// максимальный acked-оффсет ниже минимального pending.
+func (t *offsetTracker) watermarks() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]kafka.Message, 0, len(t.parts))
+	for _, po := range t.parts {
+		low := po.lowPending()
+
+		var (
+			best  kafka.Message
+			found bool
+		)
+		for off, m := range po.acked {
+			if low >= 0 && off > low {
+				continue
+			}
+			if !found || off > best.Offset {
+				best, found = m, true
+			}
+		}
+		if found {
+			out = append(out, best)
+		}
+	}
+	return out
+}
+
+// committed сдвигает базу партиций после успешного коммита и забывает
+// подтверждения, которые теперь покрыты закоммиченным оффсетом.
+func (t *offsetTracker) committed(msgs []kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range msgs {
+		po := t.part(m.Partition)
+		if m.Offset <= po.committed {
+			continue
+		}
+		po.committed = m.Offset
+		for off := range po.acked {
+			if off <= m.Offset {
+				delete(po.acked, off)
+			}
+		}
+		for off := range po.pending {
+			if off <= m.Offset {
+				delete(po.pending, off)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package kafkaio
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func msg(p int, off int64) kafka.Message { return kafka.Message{Partition: p, Offset: off} }
+
+func TestOffsetTracker_GapHoldsWatermark(t *testing.T) {
+	tr := newOffsetTracker()
+	for off := int64(1); off <= 5; off++ {
+		tr.track(msg(0, off))
+	}
+	tr.ack(msg(0, 1), msg(0, 2), msg(0, 4), msg(0, 5))
+
+	wm := tr.watermarks()
+	if len(wm) != 1 || wm[0].Offset != 2 {
+		t.Fatalf("watermark: want offset 2, got %+v", wm)
+	}
+	tr.committed(wm)
+
+	tr.ack(msg(0, 3))
+	wm = tr.watermarks()
+	if len(wm) != 1 || wm[0].Offset != 5 {
+		t.Fatalf("watermark after gap closed: want offset 5, got %+v", wm)
+	}
+}
+
+func TestOffsetTracker_RefetchOfAckedIsSkipped(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msg(1, 10))
+	tr.track(msg(1, 11))
+	tr.ack(msg(1, 11))
+
+	if !tr.track(msg(1, 10)) {
+		t.Fatal("unacked offset must be tracked again")
+	}
+	if tr.track(msg(1, 11)) {
+		t.Fatal("acked offset must be skipped on refetch")
+	}
+}
+
+func TestOffsetTracker_PartitionsIndependent(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msg(0, 1))
+	tr.track(msg(1, 1))
+	tr.ack(msg(1, 1))
+
+	wm := tr.watermarks()
+	if len(wm) != 1 || wm[0].Partition != 1 {
+		t.Fatalf("want only partition 1 committable, got %+v", wm)
+	}
+}
+
+func TestOffsetTracker_StuckOffsetBoundsAcked(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.maxAcked = 8
+	for off := int64(0); off <= 20; off++ {
+		tr.track(msg(0, off))
+	}
+	for off := int64(1); off <= 20; off++ {
+		tr.ack(msg(0, off)) // 0 так и не подтверждён
+	}
+
+	if n := len(tr.parts[0].acked); n > tr.maxAcked {
+		t.Fatalf("acked grew to %d behind a stuck offset, cap %d", n, tr.maxAcked)
+	}
+	if wm := tr.watermarks(); len(wm) != 0 {
+		t.Fatalf("committable past a stuck offset: %+v", wm)
+	}
+
+	// ребаланс до того, как дырка закрылась: забытое обрабатывается ещё раз
+	var evicted int64 = -1
+	for off := int64(1); off <= 20; off++ {
+		if _, ok := tr.parts[0].acked[off]; !ok {
+			evicted = off
+			break
+		}
+	}
+	if evicted < 0 || !tr.track(msg(0, evicted)) {
+		t.Fatalf("evicted ack %d must be processed again on refetch", evicted)
+	}
+	tr.ack(msg(0, evicted))
+
+	tr.ack(msg(0, 0))
+	if wm := tr.watermarks(); len(wm) != 1 || wm[0].Offset != 20 {
+		t.Fatalf("watermark after the gap closed: %+v", wm)
+	}
+}
+
+func TestOffsetTracker_JumpAfterRebalanceDropsStalePending(t *testing.T) {
+	tr := newOffsetTracker()
+	for off := int64(1); off <= 3; off++ {
+		tr.track(msg(0, off))
+	}
+	tr.ack(msg(0, 1), msg(0, 3)) // 2 застрял
+
+	// партиция вернулась: другой член группы закоммитил до 9
+	tr.track(msg(0, 10))
+	tr.ack(msg(0, 10))
+
+	wm := tr.watermarks()
+	if len(wm) != 1 || wm[0].Offset != 10 {
+		t.Fatalf("stale pending offset still holds the watermark: %+v", wm)
+	}
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	messageIDKey
+)
+
+// WithRequestID кладёт id HTTP-запроса в ctx для Ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// WithMessageID кладёт id сообщения Kafka в ctx для Ctx.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey, id)
+}
+
+// Ctx — глобальный логгер с полями из ctx: trace_id/span_id активного
+// span'а, request_id и message_id. По ним логи склеиваются с трейсами.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	lc := log.Logger.With()
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		lc = lc.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+	}
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		lc = lc.Str("request_id", id)
+	}
+	if id, ok := ctx.Value(messageIDKey).(string); ok && id != "" {
+		lc = lc.Str("message_id", id)
+	}
+	l := lc.Logger()
+	return &l
+}
@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"pay_flow_go/internal/config"
+	"strings"
 	t "time"
 
 	"github.com/rs/zerolog"
@@ -15,15 +17,22 @@ func Init(level zerolog.Level, cfg *config.Config) {
 		loc = t.FixedZone(cfg.Location, 6*3600)
 	}
 
-	cw := zerolog.ConsoleWriter{
+	zerolog.TimestampFunc = func() t.Time { return t.Now().In(loc) }
+	log.Logger = zerolog.New(output(cfg.LogFormat, loc)).With().Timestamp().Logger().With().Caller().Logger()
+
+	zerolog.SetGlobalLevel(level)
+}
+
+// output — json для агрегатора логов, иначе цветная консоль.
+func output(format string, loc *t.Location) io.Writer {
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		zerolog.TimeFieldFormat = t.RFC3339Nano
+		return os.Stdout
+	}
+	return zerolog.ConsoleWriter{
 		Out:          os.Stdout,
 		NoColor:      false,
 		TimeFormat:   "2006-01-02 15:04:05",
 		TimeLocation: loc,
 	}
-
-	zerolog.TimestampFunc = func() t.Time { return t.Now().In(loc) }
-	log.Logger = zerolog.New(cw).With().Timestamp().Logger().With().Caller().Logger()
-
-	zerolog.SetGlobalLevel(level)
 }
@@ -1,11 +1,18 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"pay_flow_go/internal/config"
 
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestInit_SetsGlobalLevel(t *testing.T) {
@@ -15,3 +22,58 @@ func TestInit_SetsGlobalLevel(t *testing.T) {
 		t.Fatalf("global level: want INFO, got %v", zerolog.GlobalLevel())
 	}
 }
+
+func TestCtx_AddsTraceAndIDs(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = prev }()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0xaa},
+		SpanID:     trace.SpanID{0xbb},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithMessageID(ctx, "msg-1")
+
+	Ctx(ctx).Info().Msg("hello")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("not json: %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		"trace_id":   sc.TraceID().String(),
+		"span_id":    sc.SpanID().String(),
+		"request_id": "req-1",
+		"message_id": "msg-1",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %s", k, got[k], v)
+		}
+	}
+}
+
+func TestCtx_NoSpanNoFields(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = prev }()
+
+	Ctx(context.Background()).Info().Msg("plain")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Fatalf("unexpected trace_id: %s", buf.String())
+	}
+}
+
+func TestOutput_JSON(t *testing.T) {
+	if _, console := output("json", time.UTC).(zerolog.ConsoleWriter); console {
+		t.Fatal("json format must not use ConsoleWriter")
+	}
+	if _, console := output("", time.UTC).(zerolog.ConsoleWriter); !console {
+		t.Fatal("default format must be console")
+	}
+}
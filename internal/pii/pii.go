@@ -0,0 +1,109 @@
+// Package pii маскирует персональные данные (телефон, ИИН, текст сообщения)
+// в логах и телеметрии.
+package pii
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Mode — степень маскирования.
+type Mode int32
+
+const (
+	ModeMask Mode = iota // частично: +7701***4567, 9901******12
+	ModeFull             // целиком звёздочками
+	ModeOff              // без маскирования — только для локальной разработки
+)
+
+var mode atomic.Int32 // ModeMask по умолчанию
+
+// ParseMode: "off" | "mask" | "full"; неизвестное значение — mask.
+func ParseMode(s string) Mode {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "off":
+		return ModeOff
+	case "full":
+		return ModeFull
+	default:
+		return ModeMask
+	}
+}
+
+// SetMode задаёт режим для всего процесса (PII_MASKING).
+func SetMode(m Mode) { mode.Store(int32(m)) }
+
+func current() Mode { return Mode(mode.Load()) }
+
+// Phone: +77011234567 → +7701***4567.
+func Phone(s string) string { return mask(s, 5, 4) }
+
+// IIN: 990101300012 → 9901******12.
+func IIN(s string) string { return mask(s, 4, 2) }
+
+// Text — текст сообщения (коды, суммы) не раскрывается даже частично.
+func Text(s string) string {
+	if s == "" || current() == ModeOff {
+		return s
+	}
+	return "***"
+}
+
+// mask оставляет head первых и tail последних символов. Короткие
+// значения, где после этого нечего скрыть, маскируются целиком.
+func mask(s string, head, tail int) string {
+	m := current()
+	if s == "" || m == ModeOff {
+		return s
+	}
+	r := []rune(s)
+	if m == ModeFull || len(r) <= head+tail {
+		head, tail = 0, 0
+	}
+	for i := head; i < len(r)-tail; i++ {
+		r[i] = '*'
+	}
+	return string(r)
+}
+
+// Kind — вид персональных данных по имени поля/атрибута.
+type Kind int
+
+const (
+	KindNone Kind = iota
+	KindPhone
+	KindIIN
+	KindText
+)
+
+// KindOf классифицирует ключ по последнему сегменту: "phone",
+// "sms.phone", "user.iin", "messaging.sms.text".
+func KindOf(key string) Kind {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	switch strings.ToLower(key) {
+	case "phone", "phone_number", "msisdn":
+		return KindPhone
+	case "iin":
+		return KindIIN
+	case "text", "body":
+		return KindText
+	default:
+		return KindNone
+	}
+}
+
+// Redact маскирует value по виду данных ключа key.
+func Redact(key, value string) string {
+	switch KindOf(key) {
+	case KindPhone:
+		return Phone(value)
+	case KindIIN:
+		return IIN(value)
+	case KindText:
+		return Text(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,84 @@
+package pii
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func withMode(t *testing.T, m Mode) {
+	prev := current()
+	SetMode(m)
+	t.Cleanup(func() { SetMode(prev) })
+}
+
+func TestMask_Partial(t *testing.T) {
+	withMode(t, ModeMask)
+
+	cases := []struct{ got, want string }{
+		{Phone("+77011234567"), "+7701***4567"},
+		{IIN("990101300012"), "9901******12"},
+		{Phone("12345"), "*****"}, // нечего оставить — целиком
+		{Phone(""), ""},
+		{Text("Ваш код 1234"), "***"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("got %q, want %q", c.got, c.want)
+		}
+	}
+}
+
+func TestMask_Modes(t *testing.T) {
+	withMode(t, ModeFull)
+	if got := Phone("+77011234567"); got != "************" {
+		t.Fatalf("full: %q", got)
+	}
+
+	SetMode(ModeOff)
+	if got := IIN("990101300012"); got != "990101300012" {
+		t.Fatalf("off: %q", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"off": ModeOff, "FULL": ModeFull, "mask": ModeMask, "bogus": ModeMask, "none": ModeMask, "": ModeMask} {
+		if got := ParseMode(in); got != want {
+			t.Errorf("ParseMode(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSpanExporter_RedactsAttributes(t *testing.T) {
+	withMode(t, ModeMask)
+
+	mem := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewSpanExporter(mem)))
+	_, span := tp.Tracer("test").Start(context.Background(), "send sms")
+	span.SetAttributes(
+		attribute.String("sms.phone", "+77011234567"),
+		attribute.String("user.iin", "990101300012"),
+		attribute.String("sms.sender", "KASPI"),
+	)
+	span.AddEvent("sent", trace.WithAttributes(attribute.String("text", "code 1234")))
+	span.End()
+
+	spans := mem.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("spans: %d", len(spans))
+	}
+	got := map[string]string{}
+	for _, kv := range spans[0].Attributes {
+		got[string(kv.Key)] = kv.Value.AsString()
+	}
+	if got["sms.phone"] != "+7701***4567" || got["user.iin"] != "9901******12" || got["sms.sender"] != "KASPI" {
+		t.Fatalf("attributes: %v", got)
+	}
+	if v := spans[0].Events[0].Attributes[0].Value.AsString(); v != "***" {
+		t.Fatalf("event attribute: %q", v)
+	}
+}
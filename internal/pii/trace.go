@@ -0,0 +1,61 @@
+package pii
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewSpanExporter оборачивает экспортёр: строковые атрибуты span'ов и
+// событий с ключами вида phone/iin/text маскируются перед отправкой.
+func NewSpanExporter(next sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return &spanExporter{next: next}
+}
+
+type spanExporter struct {
+	next sdktrace.SpanExporter
+}
+
+func (e *spanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	out := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		out[i] = redactedSpan{s}
+	}
+	return e.next.ExportSpans(ctx, out)
+}
+
+func (e *spanExporter) Shutdown(ctx context.Context) error { return e.next.Shutdown(ctx) }
+
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return RedactAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, ev := range events {
+		ev.Attributes = RedactAttributes(ev.Attributes)
+		out[i] = ev
+	}
+	return out
+}
+
+// RedactAttributes — копия attrs с замаскированными значениями PII.
+func RedactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if current() == ModeOff {
+		return attrs
+	}
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		if kv.Value.Type() == attribute.STRING && KindOf(string(kv.Key)) != KindNone {
+			kv = kv.Key.String(Redact(string(kv.Key), kv.Value.AsString()))
+		}
+		out[i] = kv
+	}
+	return out
+}
@@ -3,11 +3,21 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"pay_flow_go/internal/config"
+	"pay_flow_go/internal/pii"
+
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
@@ -15,7 +25,7 @@ import (
 
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
-func setupOTelSDK(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+func setupOTelSDK(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
 	var shutdownFuncs []func(context.Context) error
 	var err error
 	// shutdown calls cleanup functions registered via shutdownFuncs.
@@ -39,8 +49,14 @@ func setupOTelSDK(ctx context.Context, endpoint string) (func(context.Context) e
 	prop := newPropagator()
 	otel.SetTextMapPropagator(prop)
 
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		handleErr(err)
+		return nil, err
+	}
+
 	// Set up trace provider.
-	tracerProvider, err := newTraceProvider(ctx, endpoint)
+	tracerProvider, err := newTraceProvider(ctx, cfg, res)
 	if err != nil {
 		handleErr(err)
 		return nil, err
@@ -48,6 +64,25 @@ func setupOTelSDK(ctx context.Context, endpoint string) (func(context.Context) e
 	shutdownFuncs = append(shutdownFuncs, tracerProvider.Shutdown)
 	otel.SetTracerProvider(tracerProvider)
 
+	// Set up meter provider.
+	meterProvider, err := newMeterProvider(ctx, cfg.TelemetryEndpoint, res)
+	if err != nil {
+		handleErr(err)
+		return nil, err
+	}
+	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+	otel.SetMeterProvider(meterProvider)
+
+	// Set up Go runtime and process metrics.
+	if err = runtime.Start(runtime.WithMeterProvider(meterProvider)); err != nil {
+		handleErr(err)
+		return nil, err
+	}
+	if err = startProcessMetrics(meterProvider); err != nil {
+		handleErr(err)
+		return nil, err
+	}
+
 	return shutdown, nil
 }
 
@@ -58,24 +93,104 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTraceProvider(ctx context.Context, endpoint string) (*trace.TracerProvider, error) {
+// newResource describes this service instance. Attributes from
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME override the config.
+func newResource(ctx context.Context, cfg *config.Config) (*resource.Resource, error) {
+	tc := cfg.Telemetry
+	instanceID := tc.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(tc.ServiceName),
+		semconv.ServiceNamespace(tc.ServiceNamespace),
+		semconv.ServiceVersion(tc.ServiceVersion),
+		semconv.DeploymentEnvironment(cfg.Env),
+	}
+	if instanceID != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(instanceID))
+	}
+
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(), // last: env wins
+	)
+}
+
+// newSampler builds the sampler from config. It returns nil when
+// OTEL_TRACES_SAMPLER is set, leaving the choice to the SDK.
+func newSampler(tc config.Telemetry) (trace.Sampler, error) {
+	if os.Getenv("OTEL_TRACES_SAMPLER") != "" {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(tc.Sampler)) {
+	case "always_on":
+		return trace.AlwaysSample(), nil
+	case "always_off":
+		return trace.NeverSample(), nil
+	case "traceidratio":
+		return trace.TraceIDRatioBased(tc.SamplerRatio), nil
+	case "parentbased_always_on", "":
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return trace.ParentBased(trace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return trace.ParentBased(trace.TraceIDRatioBased(tc.SamplerRatio)), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", tc.Sampler)
+	}
+}
+
+func newTraceProvider(ctx context.Context, cfg *config.Config, res *resource.Resource) (*trace.TracerProvider, error) {
+	sampler, err := newSampler(cfg.Telemetry)
+	if err != nil {
+		return nil, err
+	}
+
 	traceExporter, err := otlptracehttp.New(
 		ctx,
-		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithEndpointURL(cfg.TelemetryEndpoint),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	traceRes := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceNamespace("go-masterclass"),
-		semconv.ServiceName("ugc-app"),
+	opts := []trace.TracerProviderOption{
+		trace.WithBatcher(pii.NewSpanExporter(traceExporter), trace.WithBatchTimeout(5*time.Second)),
+		trace.WithResource(res),
+	}
+	if sampler != nil {
+		opts = append(opts, trace.WithSampler(sampler))
+	}
+	return trace.NewTracerProvider(opts...), nil
+}
+
+func newMeterProvider(ctx context.Context, endpoint string, res *resource.Resource) (*metric.MeterProvider, error) {
+	metricExporter, err := otlpmetrichttp.New(
+		ctx,
+		otlpmetrichttp.WithEndpointURL(metricsEndpoint(endpoint)),
 	)
+	if err != nil {
+		return nil, err
+	}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithBatcher(traceExporter, trace.WithBatchTimeout(5*time.Second)),
-		trace.WithResource(traceRes),
+	meterProvider := metric.NewMeterProvider(
+		metric.WithReader(metric.NewPeriodicReader(metricExporter,
+			metric.WithInterval(15*time.Second),
+			metric.WithProducer(runtime.NewProducer()), // scheduler latency histograms
+		)),
+		metric.WithResource(res),
 	)
-	return traceProvider, nil
+	return meterProvider, nil
+}
+
+// metricsEndpoint — TelemetryEndpoint указывает на /v1/traces;
+// метрики уходят на соседний /v1/metrics того же коллектора.
+func metricsEndpoint(endpoint string) string {
+	if base, ok := strings.CutSuffix(strings.TrimRight(endpoint, "/"), "/v1/traces"); ok {
+		return base + "/v1/metrics"
+	}
+	return endpoint
 }
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"pay_flow_go/internal/config"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestNewResource_ConfigAndEnvOverride(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "from-env")
+
+	cfg := &config.Config{
+		Env: "staging",
+		Telemetry: config.Telemetry{
+			ServiceName:      "pay_flow_go",
+			ServiceNamespace: "payments",
+			ServiceVersion:   "1.2.3",
+			InstanceID:       "pod-1",
+		},
+	}
+	res, err := newResource(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		string(semconv.ServiceNameKey):           "from-env",
+		string(semconv.ServiceNamespaceKey):      "payments",
+		string(semconv.ServiceVersionKey):        "1.2.3",
+		string(semconv.ServiceInstanceIDKey):     "pod-1",
+		string(semconv.DeploymentEnvironmentKey): "staging",
+	}
+	got := map[string]string{}
+	for _, kv := range res.Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	s, err := newSampler(config.Telemetry{Sampler: "traceidratio", SamplerRatio: 0.25})
+	if err != nil || s == nil {
+		t.Fatalf("traceidratio: %v, %v", s, err)
+	}
+	if _, err := newSampler(config.Telemetry{Sampler: "sometimes"}); err == nil {
+		t.Fatal("unknown sampler must fail")
+	}
+
+	t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+	if s, err := newSampler(config.Telemetry{Sampler: "sometimes"}); s != nil || err != nil {
+		t.Fatalf("OTEL_TRACES_SAMPLER must take precedence: %v, %v", s, err)
+	}
+}
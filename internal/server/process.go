@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// startProcessMetrics registers process.* semconv metrics: uptime and,
+// where the OS supports it, CPU time by mode.
+func startProcessMetrics(mp metric.MeterProvider) error {
+	started := time.Now()
+	m := mp.Meter("pay_flow_go/internal/server")
+
+	_, err := m.Float64ObservableGauge("process.uptime",
+		metric.WithDescription("The time the process has been running"), metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(time.Since(started).Seconds())
+			return nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Float64ObservableCounter("process.cpu.time",
+		metric.WithDescription("Total CPU seconds broken down by different CPU modes"), metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			user, system, ok := cpuTimes()
+			if !ok {
+				return nil
+			}
+			o.Observe(user.Seconds(), metric.WithAttributes(attribute.String("cpu.mode", "user")))
+			o.Observe(system.Seconds(), metric.WithAttributes(attribute.String("cpu.mode", "system")))
+			return nil
+		}))
+	return err
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collect(t *testing.T, r sdkmetric.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+func newTestMeterProvider(t *testing.T, opts ...sdkmetric.ManualReaderOption) (*sdkmetric.MeterProvider, sdkmetric.Reader) {
+	t.Helper()
+	r := sdkmetric.NewManualReader(opts...)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	return mp, r
+}
+
+func TestStartProcessMetrics(t *testing.T) {
+	mp, r := newTestMeterProvider(t)
+	if err := startProcessMetrics(mp); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, r)
+	uptime, ok := got["process.uptime"].(metricdata.Gauge[float64])
+	if !ok || len(uptime.DataPoints) != 1 || uptime.DataPoints[0].Value < 0 {
+		t.Fatalf("process.uptime: %+v", got["process.uptime"])
+	}
+
+	if _, _, ok := cpuTimes(); !ok {
+		return // CPU time is not collected on this OS
+	}
+	cpu, ok := got["process.cpu.time"].(metricdata.Sum[float64])
+	if !ok || !cpu.IsMonotonic || len(cpu.DataPoints) != 2 {
+		t.Fatalf("process.cpu.time: %+v", got["process.cpu.time"])
+	}
+	modes := map[string]bool{}
+	for _, dp := range cpu.DataPoints {
+		v, _ := dp.Attributes.Value("cpu.mode")
+		modes[v.AsString()] = true
+	}
+	if !modes["user"] || !modes["system"] {
+		t.Fatalf("cpu.mode values: %v", modes)
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	mp, r := newTestMeterProvider(t, sdkmetric.WithProducer(runtime.NewProducer()))
+	if err := runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, r)
+	for _, name := range []string{"go.goroutine.count", "go.memory.used", "go.schedule.duration"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("metric %s not exported", name)
+		}
+	}
+}
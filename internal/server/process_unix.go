@@ -0,0 +1,16 @@
+//go:build unix
+
+package server
+
+import (
+	"syscall"
+	"time"
+)
+
+func cpuTimes() (user, system time.Duration, ok bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, false
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano()), true
+}
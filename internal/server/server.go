@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"time"
 	// "pay_flow_go/internal/cache"
 	"pay_flow_go/internal/config"
 	kafkaio "pay_flow_go/internal/kafka"
@@ -22,7 +23,16 @@ func New(cfg *config.Config) (*Server, error) {
 	// 	return nil, err
 	// }
 
-	prod := kafkaio.NewProducer(&cfg.Kafka)
+	if cfg.Kafka.Admin.EnsureTopics {
+		if err := EnsureTopics(context.Background(), cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	prod, err := kafkaio.NewProducer(&cfg.Kafka)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Server{
 		cfg: cfg,
@@ -32,9 +42,18 @@ func New(cfg *config.Config) (*Server, error) {
 	}, nil
 }
 
+// EnsureTopics создаёт недостающие топики сервиса (см. kafkaio.DeclaredTopics).
+func EnsureTopics(ctx context.Context, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	adm := kafkaio.NewAdmin(&cfg.Kafka)
+	return adm.EnsureTopics(ctx, kafkaio.DeclaredTopics(&cfg.Kafka))
+}
+
 func (s *Server) Run(ctx context.Context) error {
 	// Init Telemetry SDK.
-	shutdown, err := setupOTelSDK(ctx, s.cfg.TelemetryEndpoint)
+	shutdown, err := setupOTelSDK(ctx, s.cfg)
 	if err != nil {
 		return err
 	}
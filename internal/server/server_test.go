@@ -17,6 +17,9 @@ func otlpSink() (*httptest.Server, string) {
 	h.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	h.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
 	srv := httptest.NewServer(h)
 	return srv, srv.URL + "/v1/traces"
 }